@@ -0,0 +1,229 @@
+package kry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultMailboxSize = 64
+)
+
+type ActorOptions struct {
+	mailboxSize int
+}
+
+// WithMailboxSize sets how many messages the actor can hold before Send and Ask block,
+// 0 makes every Send and Ask wait for the actor to take the message.
+func WithMailboxSize(size int) func(o *ActorOptions) *ActorOptions {
+	return func(o *ActorOptions) *ActorOptions {
+		o.mailboxSize = size
+
+		return o
+	}
+}
+
+type message[Action, State comparable, Param any] struct {
+	ctx      context.Context
+	action   Action
+	newState State
+	apply    bool // true for Apply, false for Event
+	params   []Param
+	reply    chan reply[State]
+}
+
+type reply[State comparable] struct {
+	state State
+	err   error
+}
+
+// Actor owns a FSM and processes every event in a single goroutine.
+//
+// Events are queued into a bounded mailbox and handled one by one with run-to-completion
+// semantics, so callbacks never run concurrently and no locking is needed in Enter* handlers.
+type Actor[Action, State comparable, Param any] struct {
+	fsm     *FSM[Action, State, Param]
+	mailbox chan message[Action, State, Param]
+	done    chan struct{}
+	running atomic.Bool
+
+	locker  sync.RWMutex // held for reading while enqueueing, so stopping waits for the senders
+	stopped bool
+}
+
+// NewActor wraps the given FSM into an actor. The FSM must not be used directly afterwards.
+func NewActor[Action, State comparable, Param any](
+	fsm *FSM[Action, State, Param],
+	options ...func(o *ActorOptions) *ActorOptions,
+) (*Actor[Action, State, Param], error) {
+	finalOptions := &ActorOptions{
+		mailboxSize: defaultMailboxSize,
+	}
+	for _, opt := range options {
+		finalOptions = opt(finalOptions)
+	}
+
+	if finalOptions.mailboxSize < 0 {
+		return nil, fmt.Errorf("mailbox size %d: %w", finalOptions.mailboxSize, ErrNotAllowed)
+	}
+
+	return &Actor[Action, State, Param]{
+		fsm:     fsm,
+		mailbox: make(chan message[Action, State, Param], finalOptions.mailboxSize),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Run processes the mailbox until ctx is cancelled.
+//
+// On cancellation the actor stops accepting messages, and every message already accepted
+// into the mailbox is processed before Run returns. The Send and Ask calls made from then on,
+// or still waiting for room in the mailbox, fail with ErrStopped.
+func (a *Actor[Action, State, Param]) Run(ctx context.Context) error {
+	if !a.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("actor is already running or stopped: %w", ErrNotAllowed)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			close(a.done) // releases the senders waiting for room in the mailbox
+
+			a.locker.Lock()
+			a.stopped = true
+			a.locker.Unlock()
+
+			a.drain()
+
+			return ctx.Err()
+
+		case msg := <-a.mailbox:
+			a.handle(msg)
+		}
+	}
+}
+
+func (a *Actor[Action, State, Param]) handle(msg message[Action, State, Param]) {
+	if err := msg.ctx.Err(); err != nil {
+		msg.reply <- reply[State]{state: a.fsm.Current(), err: err}
+
+		return
+	}
+
+	var err error
+	if msg.apply {
+		err = a.fsm.Apply(msg.ctx, msg.action, msg.newState, msg.params...)
+	} else {
+		err = a.fsm.Event(msg.ctx, msg.action, msg.params...)
+	}
+
+	msg.reply <- reply[State]{state: a.fsm.Current(), err: err}
+}
+
+// drain handles the messages accepted before stopping, no more can be enqueued meanwhile.
+func (a *Actor[Action, State, Param]) drain() {
+	for {
+		select {
+		case msg := <-a.mailbox:
+			a.handle(msg)
+		default:
+			return
+		}
+	}
+}
+
+func (a *Actor[Action, State, Param]) enqueue(ctx context.Context, msg message[Action, State, Param]) error {
+	a.locker.RLock()
+	defer a.locker.RUnlock()
+
+	if a.stopped {
+		return fmt.Errorf("action %v: %w", msg.action, ErrStopped)
+	}
+
+	select {
+	case a.mailbox <- msg:
+		return nil
+	case <-a.done:
+		return fmt.Errorf("action %v: %w", msg.action, ErrStopped)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Actor[Action, State, Param]) wait(ctx context.Context, msg message[Action, State, Param]) (State, error) {
+	var zeroState State
+
+	// an enqueued message is always answered, either by Run or by the drain
+	select {
+	case result := <-msg.reply:
+		return result.state, result.err
+
+	case <-ctx.Done():
+		return zeroState, ctx.Err()
+	}
+}
+
+// Send enqueues the event and returns without waiting for it to be processed.
+//
+// It blocks while the mailbox is full, until ctx is done or the actor stops.
+// Once Send returns nil the event is processed, even if the actor is stopped meanwhile.
+func (a *Actor[Action, State, Param]) Send(ctx context.Context, action Action, param ...Param) error {
+	return a.enqueue(ctx, message[Action, State, Param]{
+		ctx:    context.WithoutCancel(ctx),
+		action: action,
+		params: param,
+		reply:  make(chan reply[State], 1),
+	})
+}
+
+// SendApply is like Send, but applies the action towards the given state.
+func (a *Actor[Action, State, Param]) SendApply(ctx context.Context, action Action, newState State, param ...Param) error {
+	return a.enqueue(ctx, message[Action, State, Param]{
+		ctx:      context.WithoutCancel(ctx),
+		action:   action,
+		newState: newState,
+		apply:    true,
+		params:   param,
+		reply:    make(chan reply[State], 1),
+	})
+}
+
+// Ask enqueues the event, waits until it is processed and returns the resulting state.
+func (a *Actor[Action, State, Param]) Ask(ctx context.Context, action Action, param ...Param) (State, error) {
+	msg := message[Action, State, Param]{
+		ctx:    ctx,
+		action: action,
+		params: param,
+		reply:  make(chan reply[State], 1),
+	}
+
+	if err := a.enqueue(ctx, msg); err != nil {
+		var zeroState State
+
+		return zeroState, err
+	}
+
+	return a.wait(ctx, msg)
+}
+
+// AskApply is like Ask, but applies the action towards the given state.
+func (a *Actor[Action, State, Param]) AskApply(ctx context.Context, action Action, newState State, param ...Param) (State, error) {
+	msg := message[Action, State, Param]{
+		ctx:      ctx,
+		action:   action,
+		newState: newState,
+		apply:    true,
+		params:   param,
+		reply:    make(chan reply[State], 1),
+	}
+
+	if err := a.enqueue(ctx, msg); err != nil {
+		var zeroState State
+
+		return zeroState, err
+	}
+
+	return a.wait(ctx, msg)
+}
@@ -0,0 +1,195 @@
+package kry
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_actor_ask_ok(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	})
+
+	actor, err := NewActor(machine)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = actor.Run(ctx)
+	}()
+
+	state, err := actor.Ask(ctx, "open")
+	require.NoError(t, err)
+	require.Equal(t, open, state)
+
+	state, err = actor.AskApply(ctx, "close", close)
+	require.NoError(t, err)
+	require.Equal(t, close, state)
+
+	_, err = actor.Ask(ctx, "close")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func Test_actor_send_concurrently_ok(t *testing.T) {
+	const (
+		idle int = iota + 1
+		busy
+	)
+
+	counter := 0
+	machine, _ := New(idle, []Transition[string, int, any]{
+		{
+			Name: "work", Src: []int{idle, busy}, Dst: busy,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				counter++ // no locking needed, the actor serializes callbacks
+
+				return nil
+			},
+		},
+	})
+
+	actor, err := NewActor(machine, WithMailboxSize(4))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = actor.Run(ctx)
+	}()
+
+	wg := sync.WaitGroup{}
+	for range 50 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			assert.NoError(t, actor.Send(ctx, "work"))
+		}()
+	}
+
+	wg.Wait()
+
+	state, err := actor.Ask(ctx, "work")
+	require.NoError(t, err)
+	require.Equal(t, busy, state)
+	require.Equal(t, 51, counter)
+}
+
+func Test_actor_stop_graceful(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	})
+
+	actor, err := NewActor(machine)
+	require.NoError(t, err)
+
+	require.NoError(t, actor.Send(context.Background(), "open"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, actor.Run(ctx), context.Canceled)
+	require.Equal(t, open, machine.Current()) // accepted before stopping, so processed
+	require.ErrorIs(t, actor.Run(context.Background()), ErrNotAllowed)
+
+	_, err = actor.Ask(context.Background(), "open")
+	require.ErrorIs(t, err, ErrStopped)
+	require.ErrorIs(t, actor.Send(context.Background(), "open"), ErrStopped)
+}
+
+func Test_actor_invalid_mailbox_size(t *testing.T) {
+	machine, _ := New(1, []Transition[string, int, any]{
+		{Name: "open", Src: []int{1}, Dst: 2},
+	})
+
+	_, err := NewActor(machine, WithMailboxSize(-1))
+	require.ErrorIs(t, err, ErrNotAllowed)
+
+	_, err = NewActor(machine, WithMailboxSize(0))
+	require.NoError(t, err)
+}
+
+func Test_actor_send_while_stopping(t *testing.T) {
+	const (
+		idle int = iota + 1
+		busy
+	)
+
+	handled := atomic.Int64{}
+
+	machine, _ := New(idle, []Transition[string, int, any]{
+		{
+			Name: "work", Src: []int{idle, busy}, Dst: busy,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				handled.Add(1)
+
+				return nil
+			},
+		},
+	})
+
+	actor, err := NewActor(machine, WithMailboxSize(2))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		_ = actor.Run(ctx)
+	}()
+
+	accepted := atomic.Int64{}
+	wg := sync.WaitGroup{}
+
+	for index := range 100 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := actor.Send(context.Background(), "work")
+			if err == nil {
+				accepted.Add(1)
+
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrStopped)
+		}()
+
+		if index == 50 {
+			cancel()
+		}
+	}
+
+	wg.Wait()
+	<-stopped
+
+	// every accepted message is processed, the others were told the actor stopped
+	require.Empty(t, actor.mailbox)
+	require.Equal(t, accepted.Load(), handled.Load())
+}
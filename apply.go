@@ -13,49 +13,152 @@ func (fsk *FSM[Action, State, Param]) apply(
 	from, to State,
 	param ...Param,
 ) error {
+	ctx = context.WithValue(ctx, transitionKey, callbacks.Info)
+
+	if err := fsk.authorize(ctx, callbacks, action, from, to, param...); err != nil {
+		return err
+	}
+
 	currentHistoryKeeper := fsk.historyKeeper
 
+	depth := fsk.depth
+	parentItemID := fsk.parentItemID
 	currentAction := fsk.currentAction
 	currentState := fsk.currentState
 	previousState := fsk.previousState
+	data := fsk.data
+	version := fsk.version
+	raisedLength := len(fsk.raised)
+	effectsLength := len(fsk.effects)
+	completedLength := len(fsk.completed)
+	deferredLength := len(fsk.deferred)
+	replayDeferred := fsk.replayDeferred
+	fsk.replayDeferred = false
 
 	fsk.currentAction = action
 	fsk.currentState = to
 	fsk.previousState = currentState
-	fsk.runningApply = true
+	fsk.depth++
 
-	expectFailed := fsk.checkCallbacksAgainstExpectHandlers(callbacks)
-	historyKeeper := newHistoryKeeper[Action, State](
-		fsk.historyKeeper.maxLength,
-		fsk.stackTrace,
-		fsk.cloneHandler,
-	)
+	expectFailed := fsk.checkCallbacksAgainstExpectHandlers(ctx, callbacks)
+	attemptsKeeper := fsk.newHistoryKeeper() // keeps the failed attempts, if retried
+	historyKeeper := fsk.newHistoryKeeper()
 	fsk.historyKeeper = historyKeeper
 
+	rollback := func() {
+		fsk.currentAction = currentAction
+		fsk.currentState = currentState
+		fsk.previousState = previousState
+		fsk.data = data
+		fsk.version = version
+
+		if len(fsk.raised) > raisedLength {
+			fsk.raised = fsk.raised[:raisedLength]
+		}
+
+		if len(fsk.effects) > effectsLength {
+			fsk.effects = fsk.effects[:effectsLength]
+		}
+
+		if len(fsk.deferred) > deferredLength {
+			fsk.deferred = fsk.deferred[:deferredLength]
+		}
+	}
+
 	defer func() {
-		currentHistoryKeeper.Append(historyKeeper)
+		if fsk.ignoreCurrent {
+			historyKeeper.MarkForcedReverted()
+		}
+
+		attemptsKeeper.Append(historyKeeper)
+		currentHistoryKeeper.Append(attemptsKeeper)
 		fsk.historyKeeper = currentHistoryKeeper
-		fsk.runningApply = false
+		fsk.parentItemID = parentItemID
+		fsk.depth--
 
 		if fsk.ignoreCurrent {
 			fsk.ignoreCurrent = false
 
-			fsk.currentAction = currentAction
-			fsk.currentState = currentState
-			fsk.previousState = previousState
+			rollback()
 		}
 	}()
 
-	if err := fsk.applyTransitionByLengthParams(
-		ctx, callbacks, param...,
-	); err != nil {
+	item := fsk.newItem(ctx, action, from, to, param...)
+	item.ExpectFailed = expectFailed
+	item.Deferred = replayDeferred
+	item.DataBefore = data
+	item.Depth = depth
+	fsk.parentItemID = item.ID
+	item.Description = callbacks.Info.Description
+	item.Tags = callbacks.Info.Tags // copied when kept, see PushItem
+	item.Meta = callbacks.Info.Meta
+
+	policy := fsk.retryPolicyFor(callbacks)
+	retryCtx := ctx
+	if policy.enabled() {
+		item.Attempt = 1
+		retryCtx = fsk.forkLoop(ctx)
+	}
+
+	err := fsk.callEnter(ctx, callbacks, param...)
+	fsk.stopTimer(item)
+
+	for err != nil && policy.shouldRetry(item.Attempt, errors.Unwrap(err)) {
+		if errSleep := fsk.clock.Sleep(ctx, policy.backoff(item.Attempt)); errSleep != nil {
+			break
+		}
+
+		// undo the nested steps committed by the failed attempt, the next one repeats them
+		if errCompensate := fsk.compensate(ctx, completedLength); errCompensate != nil {
+			err = fmt.Errorf("%w: %w", err, errCompensate)
+
+			break
+		}
+
+		failedItem := *item
+		failedItem.Err = errors.Unwrap(err)
+		failedItem.Ignored = fsk.ignoreCurrent
+		failedItem.DataAfter = fsk.data
+
+		if intermediateKeeper, errHistory := fsk.intermediateKeeper(
+			historyKeeper, &failedItem,
+		); errHistory != nil {
+			err = fmt.Errorf("%w: %w", err, errHistory)
+
+			break
+		} else {
+			intermediateKeeper.MarkForcedReverted()
+			attemptsKeeper.Append(intermediateKeeper)
+		}
+
+		// start over from the state the callback was called with
+		rollback()
+		fsk.currentAction = action
+		fsk.currentState = to
+		fsk.previousState = currentState
+		fsk.ignoreCurrent = false
+
+		historyKeeper = fsk.newHistoryKeeper()
+		fsk.historyKeeper = historyKeeper
+		item.Attempt++
+		item.ID = fsk.nextItemID()
+		fsk.parentItemID = item.ID
+
+		fsk.startTimer(item)
+		err = fsk.callEnter(fsk.forkLoop(retryCtx), callbacks, param...)
+		fsk.stopTimer(item)
+	}
+
+	if err != nil {
 		ignored := fsk.ignoreCurrent
 		fsk.ignoreCurrent = true
 
+		item.Err = errors.Unwrap(err)
+		item.Ignored = ignored
+		item.DataAfter = fsk.data
+
 		if intermediateKeeper, errHistory := fsk.intermediateKeeper(
-			historyKeeper,
-			action, from, to,
-			errors.Unwrap(err), ignored, expectFailed, param...,
+			historyKeeper, item,
 		); errHistory != nil {
 			err = fmt.Errorf("%w: %w", err, errHistory)
 		} else {
@@ -66,16 +169,39 @@ func (fsk *FSM[Action, State, Param]) apply(
 			action, from, to, err)
 	}
 
+	item.Ignored = fsk.ignoreCurrent
+	item.DataAfter = fsk.data
+
+	if !fsk.ignoreCurrent {
+		fsk.version++
+	}
+
+	item.Version = fsk.version
+
 	if intermediateKeeper, errHistory := fsk.intermediateKeeper(
-		historyKeeper,
-		action, from, to,
-		nil, fsk.ignoreCurrent, expectFailed, param...,
+		historyKeeper, item,
 	); errHistory != nil {
+		fsk.ignoreCurrent = true // a transition that can't be kept is not applied
+
 		return fmt.Errorf("failed to keep forced history: %w", errHistory)
 	} else {
 		historyKeeper = intermediateKeeper
 	}
 
+	if !fsk.ignoreCurrent && depth == 0 {
+		fsk.unblockingItemID = item.ID
+	}
+
+	if !fsk.ignoreCurrent && callbacks.Compensate != nil {
+		fsk.completed = append(fsk.completed, completedStep[Action, State, Param]{
+			compensate: callbacks.Compensate,
+			action:     action,
+			from:       from,
+			to:         to,
+			params:     param,
+		})
+	}
+
 	return nil
 }
 
@@ -182,6 +308,33 @@ func (fsk *FSM[Action, State, Param]) applyByMatch(ctx context.Context, action A
 	return false, nil
 }
 
+// callEnter executes the Enter* callback, bounded by the timeout of the transition if any.
+//
+// The callback is expected to honour ctx, the deadline is checked once it returns.
+func (fsk *FSM[Action, State, Param]) callEnter(
+	ctx context.Context, stateTransition callbacks[Action, State, Param], param ...Param,
+) error {
+	if stateTransition.Timeout <= 0 {
+		return fsk.applyTransitionByLengthParams(ctx, stateTransition, param...)
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, stateTransition.Timeout)
+	defer cancel()
+
+	err := fsk.applyTransitionByLengthParams(ctxWithTimeout, stateTransition, param...)
+
+	if ctx.Err() != nil || !errors.Is(ctxWithTimeout.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	if err == nil {
+		return fmt.Errorf("enter callback exceeded %v: %w", stateTransition.Timeout, ErrTimeout)
+	}
+
+	return fmt.Errorf("enter callback exceeded %v: %w",
+		stateTransition.Timeout, errors.Join(ErrTimeout, errors.Unwrap(err)))
+}
+
 func (fsk *FSM[Action, State, Param]) applyTransitionByLengthParams(
 	ctx context.Context, stateTransition callbacks[Action, State, Param], param ...Param,
 ) error {
@@ -237,8 +390,79 @@ func (fsk *FSM[Action, State, Param]) Event(
 	return nil
 }
 
+// Apply moves the machine to newState by the given action.
+//
+// If the error wraps ErrEffects the transition was committed, only the execution of its effects
+// failed and they stay pending, so the call must not be repeated. The same goes for ErrRaised,
+// only an event raised by the committed transitions failed and it was undone.
 func (fsk *FSM[Action, State, Param]) Apply(
 	ctx context.Context, action Action, newState State, param ...Param,
+) error {
+	if fsk.depth > 0 || fsk.draining {
+		err := fsk.applyAction(ctx, action, newState, param...)
+		if err != nil && fsk.tx != nil && fsk.tx.err == nil {
+			fsk.tx.err = err
+		}
+
+		return err
+	}
+
+	defer fsk.settleHistory()
+
+	completedLength := len(fsk.completed)
+
+	var errRaised error
+
+	err := fsk.applyAction(ctx, action, newState, param...)
+	if err != nil {
+		fsk.raised = nil
+	} else {
+		errRaised = fsk.drainRaised(ctx) // undone by itself, the transition stays committed
+		err = fsk.retryDeferred(ctx)
+	}
+
+	if fsk.tx != nil {
+		// the transaction decides what to do with the completed steps and effects
+		return joinErrors(err, errRaised)
+	}
+
+	if err != nil {
+		if errCompensate := fsk.compensate(ctx, completedLength); errCompensate != nil {
+			err = fmt.Errorf("%w: %w", err, errCompensate)
+		}
+	} else {
+		fsk.completed = fsk.completed[:completedLength]
+	}
+
+	// the failed transitions already discarded their effects, what's left is committed
+	errEffects := fsk.FlushEffects(ctx)
+
+	if err == nil {
+		err = fsk.complete(ctx)
+	}
+
+	return joinErrors(err, errRaised, errEffects)
+}
+
+// joinErrors is errors.Join, but a single error is returned as it is.
+func joinErrors(errs ...error) error {
+	var found []error
+
+	for _, err := range errs {
+		if err != nil {
+			found = append(found, err)
+		}
+	}
+
+	if len(found) == 1 {
+		return found[0]
+	}
+
+	return errors.Join(found...)
+}
+
+func (fsk *FSM[Action, State, Param]) applyAction(
+	ctx context.Context, action Action, newState State, param ...Param,
 ) error {
 	currentState := fsk.currentState
 
@@ -250,11 +474,7 @@ func (fsk *FSM[Action, State, Param]) Apply(
 
 			err := fmt.Errorf("%v", errPanic)
 
-			if errHistory := fsk.historyKeeper.Push(
-				action, currentState, newState,
-				err, defaultSkipStackTrace, fsk.ignoreCurrent, false,
-				param...,
-			); errHistory != nil {
+			if errHistory := fsk.keepFailure(ctx, action, currentState, newState, err, param...); errHistory != nil {
 				err = fmt.Errorf("%w: failed to push history item: %w", err, errHistory)
 			}
 
@@ -268,6 +488,23 @@ func (fsk *FSM[Action, State, Param]) Apply(
 		}
 	}()
 
+	if err := ctx.Err(); err != nil {
+		if errHistory := fsk.keepFailure(ctx, action, currentState, newState, err, param...); errHistory != nil {
+			err = fmt.Errorf("%w: failed to push history item: %w", err, errHistory)
+		}
+
+		return fmt.Errorf("refused to apply (%v): %w", action, err)
+	}
+
+	if fsk.Done() {
+		err := error(ErrDone)
+		if errHistory := fsk.keepFailure(ctx, action, currentState, newState, err, param...); errHistory != nil {
+			err = fmt.Errorf("%w: failed to push history item: %w", err, errHistory)
+		}
+
+		return fmt.Errorf("action (%v) in final state %v: %w", action, currentState, err)
+	}
+
 	ctxWithLoop, err := fsk.checkLoop(ctx, currentState, newState)
 	if err != nil {
 		return fmt.Errorf("failed to apply (%v): %w", action, err)
@@ -275,11 +512,7 @@ func (fsk *FSM[Action, State, Param]) Apply(
 
 	if _, ok := fsk.path[action]; !ok {
 		err = ErrUnknown
-		if errHistory := fsk.historyKeeper.Push(
-			action, currentState, newState,
-			err, defaultSkipStackTrace, fsk.ignoreCurrent, false,
-			param...,
-		); errHistory != nil {
+		if errHistory := fsk.keepFailure(ctx, action, currentState, newState, err, param...); errHistory != nil {
 			err = fmt.Errorf("%w: failed to push history item: %w", err, errHistory)
 		}
 
@@ -310,12 +543,12 @@ func (fsk *FSM[Action, State, Param]) Apply(
 		return nil
 	}
 
+	if fsk.isDeferredIn(action, currentState) {
+		return fsk.deferEvent(ctx, action, currentState, newState, param...)
+	}
+
 	err = ErrNotFound
-	if errHistory := fsk.historyKeeper.Push(
-		action, currentState, newState,
-		err, defaultSkipStackTrace, fsk.ignoreCurrent, false,
-		param...,
-	); errHistory != nil {
+	if errHistory := fsk.keepFailure(ctx, action, currentState, newState, err, param...); errHistory != nil {
 		err = fmt.Errorf("%w: failed to push history item: %w", err, errHistory)
 	}
 
@@ -0,0 +1,74 @@
+package kry
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Authorizer decides whether the caller carried by ctx may trigger the transition.
+// A non-nil error rejects it, the roles required by the transition are given by RequiredRoles(ctx).
+type Authorizer[Action, State comparable] = func(ctx context.Context, action Action, from, to State) error
+
+// RequiredRoles returns the roles of the transition being authorized.
+func RequiredRoles(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesKey).([]string)
+
+	return roles
+}
+
+// RolesAuthorizer returns an authorizer that requires the principal to have every role
+// of the transition. The roles of the principal are given by rolesOf.
+func RolesAuthorizer[Action, State comparable](
+	rolesOf func(ctx context.Context, principal string) []string,
+) Authorizer[Action, State] {
+	return func(ctx context.Context, action Action, from, to State) error {
+		required := RequiredRoles(ctx)
+		if len(required) == 0 {
+			return nil
+		}
+
+		principal := PrincipalFromContext(ctx)
+		granted := rolesOf(ctx, principal)
+
+		for _, role := range required {
+			if !slices.Contains(granted, role) {
+				return fmt.Errorf("principal '%s' lacks role '%s': %w", principal, role, ErrNotAllowed)
+			}
+		}
+
+		return nil
+	}
+}
+
+// authorize runs the authorizer, the rejected transitions are kept in the history.
+func (fsk *FSM[Action, State, Param]) authorize(
+	ctx context.Context,
+	callbacks callbacks[Action, State, Param],
+	action Action,
+	from, to State,
+	param ...Param,
+) error {
+	if fsk.authorizer == nil {
+		return nil
+	}
+
+	err := fsk.authorizer(context.WithValue(ctx, rolesKey, callbacks.Info.Roles), action, from, to)
+	if err == nil {
+		return nil
+	}
+
+	item := fsk.newItem(ctx, action, from, to, param...)
+	item.Err = err
+	item.Ignored = fsk.ignoreCurrent
+	item.Unauthorized = true
+	item.Description = callbacks.Info.Description
+	item.Tags = callbacks.Info.Tags // copied when kept, see PushItem
+	item.Meta = callbacks.Info.Meta
+
+	if errHistory := fsk.historyKeeper.PushItem(item, defaultSkipStackTrace); errHistory != nil {
+		err = fmt.Errorf("%w: failed to push history item: %w", err, errHistory)
+	}
+
+	return fmt.Errorf("unauthorized (%v) from '%v' to '%v': %w", action, from, to, err)
+}
@@ -0,0 +1,146 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_authorizer_rejects_before_callbacks(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	errForbidden := errors.New("forbidden")
+	called := false
+
+	authorizer := func(ctx context.Context, action string, from, to int) error {
+		if PrincipalFromContext(ctx) != "alice" {
+			return errForbidden
+		}
+
+		return nil
+	}
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				called = true
+
+				return nil
+			},
+		},
+	}, WithFullHistory[any](), WithAuthorizer[any](authorizer))
+	require.NoError(t, err)
+
+	require.ErrorIs(t, machine.Apply(ContextWithPrincipal(context.TODO(), "bob"), "open", open), errForbidden)
+	require.False(t, called)
+	require.Equal(t, close, machine.Current())
+
+	require.NoError(t, machine.Apply(ContextWithPrincipal(context.TODO(), "alice"), "open", open))
+	require.True(t, called)
+
+	require.Equal(t, []HistoryItem[string, int, any]{
+		{Action: "open", From: close, To: open, Err: errForbidden, Unauthorized: true, Principal: "bob", ID: 1},
+		{Action: "open", From: close, To: open, Principal: "alice", Version: 1, ID: 2},
+	}, machine.History())
+}
+
+func Test_authorizer_with_roles(t *testing.T) {
+	const (
+		draft int = iota + 1
+		published
+	)
+
+	roles := map[string][]string{
+		"alice": {"editor", "publisher"},
+		"bob":   {"editor"},
+	}
+
+	authorizer := RolesAuthorizer[string, int](func(ctx context.Context, principal string) []string {
+		return roles[principal]
+	})
+
+	machine, err := New(draft, []Transition[string, int, any]{
+		{Name: "publish", Src: []int{draft}, Dst: published, Roles: []string{"publisher"}},
+		{Name: "unpublish", Src: []int{published}, Dst: draft},
+	}, WithAuthorizer[any](authorizer))
+	require.NoError(t, err)
+
+	bob := ContextWithPrincipal(context.TODO(), "bob")
+	require.ErrorIs(t, machine.Event(bob, "publish"), ErrNotAllowed)
+
+	alice := ContextWithPrincipal(context.TODO(), "alice")
+	require.NoError(t, machine.Event(alice, "publish"))
+	require.NoError(t, machine.Event(bob, "unpublish")) // requires no role
+}
+
+func Test_authorizer_mismatched_type(t *testing.T) {
+	authorizer := func(ctx context.Context, action int, from, to int) error {
+		return nil
+	}
+
+	_, err := New(1, []Transition[string, int, any]{
+		{Name: "open", Src: []int{1}, Dst: 2},
+	}, WithAuthorizer[any](authorizer))
+	require.ErrorIs(t, err, ErrNotAllowed)
+}
+
+func Test_authorizer_deferred_on_behalf_of_sender(t *testing.T) {
+	const (
+		validating int = iota + 1
+		ready
+		paid
+	)
+
+	roles := map[string][]string{
+		"alice": {"payer"},
+		"admin": {"admin"},
+	}
+
+	machine, err := New(validating, []Transition[string, int, any]{
+		{Name: "ready", Src: []int{validating}, Dst: ready},
+		{Name: "pay", Src: []int{ready}, Dst: paid, DeferIn: []int{validating}, Roles: []string{"payer"}},
+	}, WithFullHistory[any](), WithAuthorizer[any](RolesAuthorizer[string, int](
+		func(ctx context.Context, principal string) []string {
+			return roles[principal]
+		},
+	)))
+	require.NoError(t, err)
+
+	// refused before being deferred
+	require.ErrorIs(t, machine.Event(ContextWithPrincipal(context.TODO(), "mallory"), "pay"), ErrNotAllowed)
+
+	require.NoError(t, machine.EventWith(context.TODO(), CallOptions[string, int, any]{
+		Principal: "alice",
+		Metadata:  map[string]any{"request": "r-1"},
+	}, "pay"))
+
+	// alice loses the role before the replay, which is authorized on her behalf
+	roles["alice"] = nil
+
+	require.NoError(t, machine.Event(ContextWithPrincipal(context.TODO(), "admin"), "ready"))
+	require.Equal(t, ready, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 4)
+	require.True(t, history[0].Unauthorized)
+	require.Equal(t, "mallory", history[0].Principal)
+	require.True(t, history[1].Deferred)
+	require.Equal(t, "alice", history[1].Principal)
+	require.Equal(t, "admin", history[2].Principal)
+	require.True(t, history[3].Unauthorized)
+	require.Equal(t, "alice", history[3].Principal)
+	require.Equal(t, map[string]any{"request": "r-1"}, history[3].CallMetadata)
+
+	// the rejected replay isn't deferred again, alice sends it once more with the role back
+	roles["alice"] = []string{"payer"}
+
+	require.NoError(t, machine.EventWith(context.TODO(), CallOptions[string, int, any]{Principal: "alice"}, "pay"))
+	require.Equal(t, paid, machine.Current())
+	require.Equal(t, "alice", machine.History()[4].Principal)
+}
@@ -0,0 +1,109 @@
+package kry
+
+import (
+	"context"
+	"maps"
+)
+
+type ctxKeyCall int
+
+const (
+	callKey       ctxKeyCall = 483 // just a random number too
+	principalKey  ctxKeyCall = 484
+	rolesKey      ctxKeyCall = 485
+	transitionKey ctxKeyCall = 486
+)
+
+// CallOptions are scoped to a single ApplyWith/EventWith call.
+//
+// Expectations are checked against the transition of the call only, while Metadata and
+// Principal are inherited by the nested transitions triggered from its callbacks.
+type CallOptions[Action, State comparable, Param any] struct {
+	// the handler types are spelled out, InstanceFSM refers to CallOptions
+	ExpectEnter         []func(ctx context.Context, instance InstanceFSM[Action, State, Param], param Param) error
+	ExpectEnterNoParams []func(ctx context.Context, instance InstanceFSM[Action, State, Param]) error
+	ExpectEnterVariadic []func(ctx context.Context, instance InstanceFSM[Action, State, Param], param ...Param) error
+
+	Metadata  map[string]any // kept in the history items of the call and its nested calls
+	Principal string         // identity of the caller, same as ContextWithPrincipal
+
+	// IdempotencyKey makes the repeated calls with the same key return the original result
+	// instead of running again, it needs WithIdempotency. It's not inherited by nested calls.
+	IdempotencyKey string
+}
+
+type callScope[Action, State comparable, Param any] struct {
+	expect   *decoratorApply[Action, State, Param] // consumed by the first transition of the call
+	started  bool                                  // true once the first transition of the call checked expect
+	metadata map[string]any
+}
+
+// ContextWithPrincipal returns a copy of ctx that carries the identity of the caller.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext returns the identity of the caller carried by ctx, if any.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalKey).(string)
+
+	return principal
+}
+
+func callScopeFromContext[Action, State comparable, Param any](ctx context.Context) *callScope[Action, State, Param] {
+	scope, _ := ctx.Value(callKey).(*callScope[Action, State, Param])
+
+	return scope
+}
+
+func (options CallOptions[Action, State, Param]) context(ctx context.Context) context.Context {
+	scope := &callScope[Action, State, Param]{}
+
+	if parent := callScopeFromContext[Action, State, Param](ctx); parent != nil && parent.metadata != nil {
+		scope.metadata = maps.Clone(parent.metadata)
+	}
+
+	if len(options.Metadata) > 0 {
+		if scope.metadata == nil {
+			scope.metadata = make(map[string]any, len(options.Metadata))
+		}
+
+		maps.Copy(scope.metadata, options.Metadata)
+	}
+
+	if len(options.ExpectEnter) > 0 || len(options.ExpectEnterNoParams) > 0 || len(options.ExpectEnterVariadic) > 0 {
+		scope.expect = &decoratorApply[Action, State, Param]{
+			expectToCallEnter:         options.ExpectEnter,
+			expectToCallEnterNoParams: options.ExpectEnterNoParams,
+			expectToCallEnterVariadic: options.ExpectEnterVariadic,
+		}
+	}
+
+	if options.Principal != "" {
+		ctx = ContextWithPrincipal(ctx, options.Principal)
+	}
+
+	return context.WithValue(ctx, callKey, scope)
+}
+
+// ApplyWith is like Apply, but with options scoped to this call instead of the sticky With.
+func (fsk *FSM[Action, State, Param]) ApplyWith(
+	ctx context.Context, options CallOptions[Action, State, Param], action Action, newState State, param ...Param,
+) error {
+	ctx = options.context(ctx)
+
+	return fsk.idempotent(ctx, options.IdempotencyKey, action, newState, func() error {
+		return fsk.Apply(ctx, action, newState, param...)
+	}, param...)
+}
+
+// EventWith is like Event, but with options scoped to this call instead of the sticky With.
+func (fsk *FSM[Action, State, Param]) EventWith(
+	ctx context.Context, options CallOptions[Action, State, Param], action Action, param ...Param,
+) error {
+	ctx = options.context(ctx)
+
+	return fsk.idempotent(ctx, options.IdempotencyKey, action, fsk.events[action].Dst, func() error {
+		return fsk.Event(ctx, action, param...)
+	}, param...)
+}
@@ -0,0 +1,163 @@
+package kry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apply_with_expectations_scoped_to_call(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	type instance = InstanceFSM[string, int, any]
+
+	handlerRoger := func(ctx context.Context, instance instance) error {
+		return nil
+	}
+
+	handlerOpen := func(ctx context.Context, instance instance) error {
+		return instance.Apply(ctx, "roger", roger)
+	}
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open, EnterNoParams: handlerOpen},
+		{Name: "roger", Src: []int{open}, Dst: roger, EnterNoParams: handlerRoger},
+		{Name: "close", Src: []int{roger}, Dst: close},
+	}, WithFullHistory[any]())
+
+	options := CallOptions[string, int, any]{
+		ExpectEnterNoParams: []func(ctx context.Context, instance instance) error{handlerRoger},
+	}
+
+	require.NoError(t, machine.ApplyWith(context.TODO(), options, "open", open))
+	require.NoError(t, machine.Apply(context.TODO(), "close", close))
+
+	expectedHistory := []HistoryItem[string, int, any]{
+		{Action: "open", From: close, To: open, ExpectFailed: true, Version: 2, ID: 1},
+		{Action: "roger", From: open, To: roger, Version: 1, Depth: 1, ID: 2, ParentID: 1},
+		{Action: "close", From: roger, To: close, Version: 3, ID: 3},
+	}
+	require.Equal(t, expectedHistory, machine.History())
+}
+
+func Test_apply_with_leaves_sticky_expectations_alone(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	type instance = InstanceFSM[string, int, any]
+
+	handlerOpen := func(ctx context.Context, instance instance) error {
+		return nil
+	}
+
+	handlerClose := func(ctx context.Context, instance instance) error {
+		return nil
+	}
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open, EnterNoParams: handlerOpen},
+		{Name: "close", Src: []int{open}, Dst: close, EnterNoParams: handlerClose},
+	}, WithFullHistory[any]())
+
+	sticky := machine.With(ExpectEnterNoParams(handlerClose))
+
+	// the call has no expectations of its own and doesn't take the sticky ones
+	require.NoError(t, machine.ApplyWith(context.TODO(), CallOptions[string, int, any]{}, "open", open))
+	require.NoError(t, sticky.Apply(context.TODO(), "close", close))
+
+	history := machine.History()
+	require.Len(t, history, 2)
+	require.False(t, history[0].ExpectFailed)
+	require.False(t, history[1].ExpectFailed) // close did call handlerClose
+	require.Nil(t, machine.decoratorApply.expectToCallEnterNoParams)
+}
+
+func Test_apply_with_metadata_inherited_by_nested_calls(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	type instance = InstanceFSM[string, int, any]
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				require.Equal(t, "kry", PrincipalFromContext(ctx))
+
+				return instance.ApplyWith(ctx, CallOptions[string, int, any]{
+					Metadata: map[string]any{"step": 2},
+				}, "roger", roger)
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+	}, WithFullHistory[any]())
+
+	require.NoError(t, machine.EventWith(context.TODO(), CallOptions[string, int, any]{
+		Metadata:  map[string]any{"request": "r-1", "step": 1},
+		Principal: "kry",
+	}, "open"))
+	require.Equal(t, roger, machine.Current())
+
+	expectedHistory := []HistoryItem[string, int, any]{
+		{
+			Action: "open", From: close, To: open,
+			Principal:    "kry",
+			CallMetadata: map[string]any{"request": "r-1", "step": 1},
+			Version:      2,
+			ID:           1,
+		},
+		{
+			Action: "roger", From: open, To: roger,
+			Principal:    "kry",
+			CallMetadata: map[string]any{"request": "r-1", "step": 2},
+			Version:      1,
+			Depth:        1,
+			ID:           2,
+			ParentID:     1,
+		},
+	}
+	require.Equal(t, expectedHistory, machine.History())
+}
+
+func Test_apply_with_metadata_not_shared(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.Apply(ctx, "roger", roger)
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+	}, WithFullHistory[any]())
+
+	metadata := map[string]any{"request": "r-1"}
+
+	require.NoError(t, machine.EventWith(context.TODO(), CallOptions[string, int, any]{
+		Metadata: metadata,
+	}, "open"))
+
+	metadata["request"] = "changed"
+
+	history := machine.History()
+	history[0].CallMetadata["request"] = "changed"
+
+	history = machine.History()
+	require.Len(t, history, 2)
+	require.Equal(t, map[string]any{"request": "r-1"}, history[1].CallMetadata)
+}
@@ -42,6 +42,32 @@ func (ld loopDetection[State]) Get(id uint64, stateFrom, stateTo State) int {
 	return ld[id][stateFrom][stateTo]
 }
 
+func (ld loopDetection[State]) clone() loopDetection[State] {
+	result := loopDetection[State]{}
+	for id, fromStates := range ld {
+		result[id] = make(map[State]map[State]int, len(fromStates))
+		for stateFrom, toStates := range fromStates {
+			result[id][stateFrom] = make(map[State]int, len(toStates))
+			for stateTo, count := range toStates {
+				result[id][stateFrom][stateTo] = count
+			}
+		}
+	}
+
+	return result
+}
+
+// forkLoop returns ctx with a copy of its loop detection,
+// so the nested transitions of a failed attempt aren't taken as a loop by the next one.
+func (fsk *FSM[Action, State, Param]) forkLoop(ctx context.Context) context.Context {
+	loopEx, ok := ctx.Value(loopKey).(loopDetection[State])
+	if !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, loopKey, loopEx.clone())
+}
+
 func (fsk *FSM[Action, State, Param]) checkLoop(
 	ctx context.Context,
 	currentState,
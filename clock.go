@@ -0,0 +1,31 @@
+package kry
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is the source of time of the FSM, it can be replaced in tests by WithClock.
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for the duration, or returns the ctx error if ctx is done first.
+	Sleep(ctx context.Context, duration time.Duration) error
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,172 @@
+package kry
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// DeepClone is a clone handler for WithCloneHandler that copies the params recursively.
+//
+// Pointers, interfaces, maps, slices, arrays and structs are copied, unexported fields included.
+// Shared and cyclic references are kept as such in the copy. Channels, functions and
+// unsafe pointers can't be copied, so they're shared with the original params.
+//
+// The types of the time, sync and sync/atomic packages aren't followed into: time.Time and
+// the atomic values are copied as values, the locks, wait groups and the like are zeroed
+// in the copy, and the pointers to any of them are shared with the original params.
+func DeepClone[Param any](params ...Param) (cloned []Param, err error) {
+	if len(params) == 0 {
+		return params, nil
+	}
+
+	defer func() {
+		if errPanic := recover(); errPanic != nil {
+			cloned = nil
+			err = fmt.Errorf("failed to deep clone params: %v", errPanic)
+		}
+	}()
+
+	c := &deepCloner{visited: map[visit]reflect.Value{}}
+	cloned = make([]Param, len(params))
+
+	for index := range params {
+		reflect.ValueOf(&cloned[index]).Elem().Set(
+			c.clone(reflect.ValueOf(&params[index]).Elem()),
+		)
+	}
+
+	return cloned, nil
+}
+
+// visit identifies a reference already cloned, the length tells apart the subslices.
+type visit struct {
+	pointer  uintptr
+	typ      reflect.Type
+	length   int
+	capacity int
+}
+
+type deepCloner struct {
+	visited map[visit]reflect.Value
+}
+
+// opaquePackages are the packages whose structs are handled as a whole, see DeepClone.
+var opaquePackages = map[string]bool{
+	"time":        true,
+	"sync":        true,
+	"sync/atomic": true,
+}
+
+func isOpaque(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct && opaquePackages[typ.PkgPath()]
+}
+
+func (c *deepCloner) clone(src reflect.Value) reflect.Value {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() || isOpaque(src.Type().Elem()) {
+			return src
+		}
+
+		key := visit{pointer: src.Pointer(), typ: src.Type()}
+		if dst, ok := c.visited[key]; ok {
+			return dst
+		}
+
+		dst := reflect.New(src.Type().Elem())
+		c.visited[key] = dst
+		dst.Elem().Set(c.clone(src.Elem()))
+
+		return dst
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return src
+		}
+
+		dst := reflect.New(src.Type()).Elem()
+		dst.Set(c.clone(src.Elem()))
+
+		return dst
+
+	case reflect.Struct:
+		if isOpaque(src.Type()) {
+			if src.Type().PkgPath() == "sync" {
+				return reflect.Zero(src.Type()) // a lock held by the original must not be held by the copy
+			}
+
+			return src
+		}
+
+		// an addressable copy, so the unexported fields can be read
+		shallow := reflect.New(src.Type()).Elem()
+		shallow.Set(src)
+
+		dst := reflect.New(src.Type()).Elem()
+		for index := range src.NumField() {
+			accessible(dst.Field(index)).Set(c.clone(accessible(shallow.Field(index))))
+		}
+
+		return dst
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return src
+		}
+
+		key := visit{pointer: src.Pointer(), typ: src.Type(), length: src.Len(), capacity: src.Cap()}
+		if dst, ok := c.visited[key]; ok {
+			return dst
+		}
+
+		dst := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		c.visited[key] = dst
+
+		for index := range src.Len() {
+			dst.Index(index).Set(c.clone(src.Index(index)))
+		}
+
+		return dst
+
+	case reflect.Array:
+		dst := reflect.New(src.Type()).Elem()
+		for index := range src.Len() {
+			dst.Index(index).Set(c.clone(src.Index(index)))
+		}
+
+		return dst
+
+	case reflect.Map:
+		if src.IsNil() {
+			return src
+		}
+
+		key := visit{pointer: src.Pointer(), typ: src.Type()}
+		if dst, ok := c.visited[key]; ok {
+			return dst
+		}
+
+		dst := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.visited[key] = dst
+
+		iterator := src.MapRange()
+		for iterator.Next() {
+			dst.SetMapIndex(c.clone(iterator.Key()), c.clone(iterator.Value()))
+		}
+
+		return dst
+
+	default:
+		return src
+	}
+}
+
+// accessible returns the addressable field so it can be read and set even if it's unexported.
+func accessible(field reflect.Value) reflect.Value {
+	if field.CanSet() {
+		return field
+	}
+
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+}
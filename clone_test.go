@@ -0,0 +1,189 @@
+package kry
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	ID       int
+	Items    []string
+	Labels   map[string]string
+	Customer *customer
+	note     *string
+	Any      any
+	Notify   func()
+}
+
+type customer struct {
+	Name   string
+	Orders []*order // may point back to the order, making a cycle
+}
+
+func Test_deep_clone(t *testing.T) {
+	note := "fragile"
+	original := &order{
+		ID:       1,
+		Items:    []string{"book"},
+		Labels:   map[string]string{"priority": "high"},
+		Customer: &customer{Name: "Ann"},
+		note:     &note,
+		Any:      []int{1, 2},
+	}
+	original.Customer.Orders = []*order{original}
+
+	cloned, err := DeepClone(original, original)
+	require.NoError(t, err)
+	require.Len(t, cloned, 2)
+	require.Same(t, cloned[0], cloned[1], "shared references are kept shared")
+	require.Equal(t, original, cloned[0])
+
+	clone := cloned[0]
+	require.NotSame(t, original, clone)
+	require.NotSame(t, original.Customer, clone.Customer)
+	require.NotSame(t, original.note, clone.note)
+	require.Same(t, clone, clone.Customer.Orders[0], "the cycle is kept in the copy")
+
+	original.Items[0] = "pen"
+	original.Labels["priority"] = "low"
+	original.Customer.Name = "Bob"
+	*original.note = "solid"
+	original.Any.([]int)[0] = 42
+
+	require.Equal(t, []string{"book"}, clone.Items)
+	require.Equal(t, "high", clone.Labels["priority"])
+	require.Equal(t, "Ann", clone.Customer.Name)
+	require.Equal(t, "fragile", *clone.note)
+	require.Equal(t, []int{1, 2}, clone.Any)
+
+	empty, err := DeepClone[any]()
+	require.NoError(t, err)
+	require.Empty(t, empty)
+
+	values, err := DeepClone[any](nil, 1, "text", [2][]int{{1}, {2}})
+	require.NoError(t, err)
+	require.Equal(t, []any{nil, 1, "text", [2][]int{{1}, {2}}}, values)
+}
+
+func Test_deep_clone_keeps_history_intact(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, *order]{
+		{Name: "open", Src: []int{close}, Dst: open},
+	}, WithFullHistory[*order](), WithCloneHandler(DeepClone[*order]))
+
+	param := &order{ID: 1, Items: []string{"book"}}
+	require.NoError(t, machine.Event(context.TODO(), "open", param))
+
+	param.Items[0] = "pen"
+	require.Equal(t, []string{"book"}, machine.History()[0].Params[0].Items)
+}
+
+func benchmarkOrder() *order {
+	note := "fragile"
+
+	return &order{
+		ID:       1,
+		Items:    []string{"book", "pen", "paper"},
+		Labels:   map[string]string{"priority": "high", "gift": "yes"},
+		Customer: &customer{Name: "Ann"},
+		note:     &note,
+		Any:      []int{1, 2, 3},
+	}
+}
+
+func Benchmark_clone_shallow(b *testing.B) {
+	param := benchmarkOrder()
+
+	for range b.N {
+		if _, err := cloneHandler(param); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_clone_deep(b *testing.B) {
+	param := benchmarkOrder()
+
+	for range b.N {
+		if _, err := DeepClone(param); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkApply(b *testing.B, options ...func(o *Options[*order]) *Options[*order]) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, *order]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, append(options, WithHistory[*order](100))...)
+
+	param := benchmarkOrder()
+	ctx := context.Background()
+
+	b.ResetTimer()
+
+	for range b.N {
+		if err := machine.Event(ctx, "open", param); err != nil {
+			b.Fatal(err)
+		}
+
+		if err := machine.Event(ctx, "close", param); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_apply_shallow_clone(b *testing.B) {
+	benchmarkApply(b)
+}
+
+func Benchmark_apply_deep_clone(b *testing.B) {
+	benchmarkApply(b, WithCloneHandler(DeepClone[*order]))
+}
+
+type schedule struct {
+	At       time.Time
+	Location *time.Location
+	Guard    sync.Mutex
+	Shared   *sync.WaitGroup
+	Count    atomic.Int64
+}
+
+func Test_deep_clone_time_and_sync(t *testing.T) {
+	location, err := time.LoadLocation("UTC")
+	require.NoError(t, err)
+
+	original := &schedule{
+		At:       time.Date(2026, 1, 2, 3, 4, 5, 0, location),
+		Location: location,
+		Shared:   &sync.WaitGroup{},
+	}
+	original.Count.Store(7)
+	original.Guard.Lock()
+
+	cloned, err := DeepClone(original)
+	require.NoError(t, err)
+
+	clone := cloned[0]
+	require.True(t, original.At.Equal(clone.At))
+	require.Same(t, original.Location, clone.Location)
+	require.Same(t, original.Shared, clone.Shared)
+	require.Equal(t, int64(7), clone.Count.Load())
+	require.True(t, clone.Guard.TryLock(), "the lock of the original isn't held by the copy")
+
+	original.Guard.Unlock()
+}
@@ -0,0 +1,49 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type completedStep[Action, State comparable, Param any] struct {
+	compensate handlerVariadic[Action, State, Param]
+	action     Action
+	from       State
+	to         State
+	params     []Param
+}
+
+// compensate runs, in reverse order, the compensations of the steps completed after the mark.
+//
+// Every compensation runs even if a previous one failed, and its outcome is kept in the history.
+func (fsk *FSM[Action, State, Param]) compensate(ctx context.Context, mark int) error {
+	if len(fsk.completed) <= mark {
+		return nil
+	}
+
+	steps := fsk.completed[mark:]
+	fsk.completed = fsk.completed[:mark]
+
+	var errs []error
+
+	for index := len(steps) - 1; index >= 0; index-- {
+		step := steps[index]
+
+		err := step.compensate(ctx, fsk, step.params...)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to compensate (%v) from '%v' to '%v': %w",
+				step.action, step.from, step.to, err))
+		}
+
+		item := fsk.newItem(ctx, step.action, step.from, step.to, step.params...)
+		item.Err = err
+		item.Compensation = true
+
+		if errHistory := fsk.historyKeeper.PushItem(item, defaultSkipStackTrace); errHistory != nil {
+			errs = append(errs, fmt.Errorf("failed to push history item: %w", errHistory))
+		}
+	}
+
+	return errors.Join(errs...)
+}
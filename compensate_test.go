@@ -0,0 +1,151 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_compensate_nested_chain(t *testing.T) {
+	const (
+		idle int = iota + 1
+		booking
+		reserved
+		charged
+	)
+
+	type instance = InstanceFSM[string, int, string]
+
+	errCharge := errors.New("card declined")
+	compensated := []string{}
+
+	machine, _ := New(idle, []Transition[string, int, string]{
+		{
+			Name: "book", Src: []int{idle}, Dst: booking,
+			Enter: func(ctx context.Context, instance instance, param string) error {
+				if err := instance.Apply(ctx, "reserve", reserved, param); err != nil {
+					return err
+				}
+
+				return instance.Apply(ctx, "charge", charged, param)
+			},
+		},
+		{
+			Name: "reserve", Src: []int{booking}, Dst: reserved,
+			Compensate: func(ctx context.Context, instance instance, param ...string) error {
+				compensated = append(compensated, "reserve:"+param[0])
+
+				return nil
+			},
+		},
+		{
+			Name: "charge", Src: []int{reserved}, Dst: charged,
+			Enter: func(ctx context.Context, instance instance, param string) error {
+				return errCharge
+			},
+			Compensate: func(ctx context.Context, instance instance, param ...string) error {
+				compensated = append(compensated, "charge")
+
+				return nil
+			},
+		},
+	}, WithFullHistory[string]())
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "book", booking, "room-1"), errCharge)
+	require.Equal(t, idle, machine.Current())
+	require.Equal(t, []string{"reserve:room-1"}, compensated)
+
+	history := machine.History()
+	require.Len(t, history, 4)
+
+	last := history[len(history)-1]
+	require.True(t, last.Compensation)
+	require.Equal(t, "reserve", last.Action)
+	require.NoError(t, last.Err)
+}
+
+func Test_compensate_sequence_reverse_order(t *testing.T) {
+	const (
+		idle int = iota + 1
+		reserved
+		charged
+		confirmed
+	)
+
+	type instance = InstanceFSM[string, int, any]
+
+	errConfirm := errors.New("confirmation failed")
+	errRefund := errors.New("refund failed")
+	compensated := []string{}
+
+	machine, _ := New(idle, []Transition[string, int, any]{
+		{
+			Name: "reserve", Src: []int{idle}, Dst: reserved,
+			Compensate: func(ctx context.Context, instance instance, param ...any) error {
+				compensated = append(compensated, "reserve")
+
+				return nil
+			},
+		},
+		{
+			Name: "charge", Src: []int{reserved}, Dst: charged,
+			Compensate: func(ctx context.Context, instance instance, param ...any) error {
+				compensated = append(compensated, "charge")
+
+				return errRefund
+			},
+		},
+		{
+			Name: "confirm", Src: []int{charged}, Dst: confirmed,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				return errConfirm
+			},
+		},
+	}, WithFullHistory[any]())
+
+	err := machine.ApplySequence(context.TODO(),
+		Step[string, int, any]{Action: "reserve", Dst: reserved},
+		Step[string, int, any]{Action: "charge", Dst: charged},
+		Step[string, int, any]{Action: "confirm", Dst: confirmed},
+	)
+	require.ErrorIs(t, err, ErrRolledBack)
+	require.ErrorIs(t, err, errConfirm)
+	require.ErrorIs(t, err, errRefund)
+	require.Equal(t, idle, machine.Current())
+	require.Equal(t, []string{"charge", "reserve"}, compensated)
+
+	history := machine.History()
+	require.Len(t, history, 5)
+	require.True(t, history[2].RolledBack)
+	require.True(t, history[3].Compensation)
+	require.ErrorIs(t, history[3].Err, errRefund)
+	require.True(t, history[4].Compensation)
+	require.False(t, history[4].RolledBack)
+}
+
+func Test_compensate_not_called_on_success(t *testing.T) {
+	const (
+		idle int = iota + 1
+		reserved
+	)
+
+	machine, _ := New(idle, []Transition[string, int, any]{
+		{
+			Name: "reserve", Src: []int{idle}, Dst: reserved,
+			Compensate: func(ctx context.Context, instance InstanceFSM[string, int, any], param ...any) error {
+				t.Fatal("compensation should not be called")
+
+				return nil
+			},
+		},
+		{Name: "release", Src: []int{reserved}, Dst: idle},
+	})
+
+	require.NoError(t, machine.ApplySequence(context.TODO(),
+		Step[string, int, any]{Action: "reserve", Dst: reserved},
+		Step[string, int, any]{Action: "release", Dst: idle},
+	))
+	require.ErrorIs(t, machine.Apply(context.TODO(), "unknown", idle), ErrUnknown)
+}
@@ -6,6 +6,20 @@ var (
 	idMachine uint64
 )
 
+func newCallbacks[Action, State comparable, Param any](
+	transition Transition[Action, State, Param],
+) callbacks[Action, State, Param] {
+	return callbacks[Action, State, Param]{
+		EnterVariadic: transition.EnterVariadic,
+		Enter:         transition.Enter,
+		EnterNoParams: transition.EnterNoParams,
+		Compensate:    transition.Compensate,
+		Retry:         transition.Retry,
+		Timeout:       transition.Timeout,
+		Info:          newTransitionInfo(transition),
+	}
+}
+
 func constructFromTransitions[Action, State comparable, Param any](
 	initialState State,
 	transitions []Transition[Action, State, Param],
@@ -45,13 +59,9 @@ func constructFromTransitions[Action, State comparable, Param any](
 			}
 
 			pathMatch[action] = append(pathMatch[action], matchState[Action, State, Param]{
-				MatchSrc: transition.SrcFn,
-				MatchDst: transition.DstFn,
-				Callbacks: callbacks[Action, State, Param]{
-					EnterVariadic: transition.EnterVariadic,
-					Enter:         transition.Enter,
-					EnterNoParams: transition.EnterNoParams,
-				},
+				MatchSrc:  transition.SrcFn,
+				MatchDst:  transition.DstFn,
+				Callbacks: newCallbacks(transition),
 			})
 
 			continue
@@ -80,12 +90,8 @@ func constructFromTransitions[Action, State comparable, Param any](
 
 				states[src] = struct{}{}
 				pathByMatchDst[action][src] = append(pathByMatchDst[action][src], matchState[Action, State, Param]{
-					MatchDst: transition.DstFn,
-					Callbacks: callbacks[Action, State, Param]{
-						EnterVariadic: transition.EnterVariadic,
-						Enter:         transition.Enter,
-						EnterNoParams: transition.EnterNoParams,
-					},
+					MatchDst:  transition.DstFn,
+					Callbacks: newCallbacks(transition),
 				})
 			}
 		}
@@ -108,12 +114,8 @@ func constructFromTransitions[Action, State comparable, Param any](
 			}
 
 			pathByMatchSrc[action][dst] = append(pathByMatchSrc[action][dst], matchState[Action, State, Param]{
-				MatchSrc: transition.SrcFn,
-				Callbacks: callbacks[Action, State, Param]{
-					EnterVariadic: transition.EnterVariadic,
-					Enter:         transition.Enter,
-					EnterNoParams: transition.EnterNoParams,
-				},
+				MatchSrc:  transition.SrcFn,
+				Callbacks: newCallbacks(transition),
 			})
 		}
 
@@ -127,11 +129,7 @@ func constructFromTransitions[Action, State comparable, Param any](
 			}
 
 			states[src] = struct{}{}
-			path[action][dst][src] = callbacks[Action, State, Param]{
-				EnterVariadic: transition.EnterVariadic,
-				Enter:         transition.Enter,
-				EnterNoParams: transition.EnterNoParams,
-			}
+			path[action][dst][src] = newCallbacks(transition)
 		}
 
 		events[action] = transition
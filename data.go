@@ -0,0 +1,39 @@
+package kry
+
+import "fmt"
+
+// DataOf returns the extended state of the instance as Data.
+//
+// The zero value and true are returned if the data is not set,
+// the zero value and false if it's of a different type.
+func DataOf[Data any, Action, State comparable, Param any](instance InstanceFSM[Action, State, Param]) (Data, bool) {
+	current := instance.Data()
+	if current == nil {
+		var zero Data
+
+		return zero, true
+	}
+
+	data, ok := current.(Data)
+
+	return data, ok
+}
+
+// UpdateData replaces the extended state of the instance with the result of update.
+//
+// It fails with ErrNotAllowed, without calling update, if the data is of a different type.
+func UpdateData[Data any, Action, State comparable, Param any](
+	instance InstanceFSM[Action, State, Param],
+	update func(data Data) Data,
+) error {
+	data, ok := DataOf[Data](instance)
+	if !ok {
+		var zero Data
+
+		return fmt.Errorf("data %T isn't %T: %w", instance.Data(), zero, ErrNotAllowed)
+	}
+
+	instance.SetData(update(data))
+
+	return nil
+}
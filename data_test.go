@@ -0,0 +1,143 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type cart struct {
+	Items int
+}
+
+func Test_data_updated_in_callbacks(t *testing.T) {
+	const (
+		empty int = iota + 1
+		filled
+	)
+
+	type instance = InstanceFSM[string, int, int]
+
+	errExpected := errors.New("expected error")
+
+	machine, _ := New(empty, []Transition[string, int, int]{
+		{
+			Name: "add", Src: []int{empty, filled}, Dst: filled,
+			Enter: func(ctx context.Context, instance instance, param int) error {
+				if err := UpdateData(instance, func(data cart) cart {
+					data.Items += param
+
+					return data
+				}); err != nil {
+					return err
+				}
+
+				if param < 0 {
+					return errExpected
+				}
+
+				return nil
+			},
+		},
+		{
+			Name: "skip", Src: []int{filled}, Dst: empty,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				instance.SetData(cart{})
+				instance.IgnoreCurrentTransition()
+
+				return nil
+			},
+		},
+	}, WithFullHistory[int](), WithData[int](cart{}))
+
+	require.NoError(t, machine.Apply(context.TODO(), "add", filled, 2))
+	require.Equal(t, cart{Items: 2}, machine.Data())
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "add", filled, -1), errExpected)
+	require.Equal(t, cart{Items: 2}, machine.Data())
+
+	require.NoError(t, machine.Apply(context.TODO(), "skip", empty))
+	require.Equal(t, filled, machine.Current())
+	require.Equal(t, cart{Items: 2}, machine.Data())
+
+	history := machine.History()
+	require.Len(t, history, 3)
+	require.Equal(t, cart{}, history[0].DataBefore)
+	require.Equal(t, cart{Items: 2}, history[0].DataAfter)
+	require.Equal(t, cart{Items: 1}, history[1].DataAfter)
+	require.True(t, history[2].Ignored)
+	require.Equal(t, cart{}, history[2].DataAfter)
+}
+
+func Test_snapshot_restore(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	transitions := []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}
+
+	machine, _ := New(close, transitions, WithData[any](cart{Items: 1}))
+	require.NoError(t, machine.Apply(context.TODO(), "open", open))
+
+	snapshot := machine.Snapshot()
+	require.Equal(t, Snapshot[int]{Current: open, Previous: close, Data: cart{Items: 1}, Version: 1}, snapshot)
+
+	restored, _ := New(close, transitions)
+	require.NoError(t, restored.Restore(snapshot))
+	require.Equal(t, open, restored.Current())
+	require.Equal(t, close, restored.Previous())
+
+	data, ok := DataOf[cart](restored)
+	require.True(t, ok)
+	require.Equal(t, cart{Items: 1}, data)
+	require.Equal(t, uint64(1), restored.Version())
+
+	require.ErrorIs(t, restored.Restore(Snapshot[int]{Current: 42}), ErrUnknown)
+}
+
+func Test_data_of_another_type(t *testing.T) {
+	const (
+		empty int = iota + 1
+		filled
+	)
+
+	type instance = InstanceFSM[string, int, int]
+
+	updated := false
+
+	machine, _ := New(empty, []Transition[string, int, int]{
+		{
+			Name: "add", Src: []int{empty}, Dst: filled,
+			Enter: func(ctx context.Context, instance instance, param int) error {
+				return UpdateData(instance, func(data int) int {
+					updated = true
+
+					return data + param
+				})
+			},
+		},
+	}, WithData[int](cart{Items: 3}))
+
+	_, ok := DataOf[int](machine)
+	require.False(t, ok)
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "add", filled, 2), ErrNotAllowed)
+	require.False(t, updated)
+	require.Equal(t, empty, machine.Current())
+	require.Equal(t, cart{Items: 3}, machine.Data())
+
+	// the data not set yet is the zero value of any type
+	unset, _ := New(empty, []Transition[string, int, int]{
+		{Name: "add", Src: []int{empty}, Dst: filled},
+	})
+
+	data, ok := DataOf[cart](unset)
+	require.True(t, ok)
+	require.Equal(t, cart{}, data)
+}
@@ -0,0 +1,199 @@
+package kry
+
+import (
+	"context"
+	"fmt"
+)
+
+type deferredEvent[Action, State comparable, Param any] struct {
+	action   Action
+	newState State
+	params   []Param
+
+	// the caller who sent it, the replay is authorized and kept in the history on its behalf
+	principal string
+	scope     *callScope[Action, State, Param]
+}
+
+// context returns ctx carrying the caller of the deferred event instead of its own.
+func (event deferredEvent[Action, State, Param]) context(ctx context.Context) context.Context {
+	return context.WithValue(ContextWithPrincipal(ctx, event.principal), callKey, event.scope)
+}
+
+func constructDeferIn[Action, State comparable, Param any](
+	transitions []Transition[Action, State, Param],
+) map[Action]map[State]struct{} {
+	deferIn := make(map[Action]map[State]struct{})
+
+	for _, transition := range transitions {
+		if len(transition.DeferIn) == 0 {
+			continue
+		}
+
+		if _, ok := deferIn[transition.Name]; !ok {
+			deferIn[transition.Name] = make(map[State]struct{})
+		}
+
+		for _, state := range transition.DeferIn {
+			deferIn[transition.Name][state] = struct{}{}
+		}
+	}
+
+	return deferIn
+}
+
+func (fsk *FSM[Action, State, Param]) isDeferredIn(action Action, state State) bool {
+	_, ok := fsk.deferIn[action][state]
+
+	return ok
+}
+
+// hasTransition tells if the action is able to move the machine from one state to another,
+// it follows the same lookup order as Apply but doesn't execute anything.
+func (fsk *FSM[Action, State, Param]) hasTransition(action Action, from, to State) bool {
+	if _, ok := fsk.path[action][to][from]; ok {
+		return true
+	}
+
+	for _, matchState := range fsk.pathByMatchSrc[action][to] {
+		if matchState.MatchSrc(from) {
+			return true
+		}
+	}
+
+	for _, matchState := range fsk.pathByMatchDst[action][from] {
+		if matchState.MatchDst(to) {
+			return true
+		}
+	}
+
+	for _, matchState := range fsk.pathMatch[action] {
+		if matchState.MatchSrc(from) && matchState.MatchDst(to) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deferredInfo returns the transition the deferred action is expected to apply.
+func (fsk *FSM[Action, State, Param]) deferredInfo(action Action, to State) *TransitionInfo[Action, State] {
+	var found *TransitionInfo[Action, State]
+
+	for index := range fsk.transitions {
+		info := &fsk.transitions[index]
+		if info.Name != action {
+			continue
+		}
+
+		if info.Dst == to {
+			return info
+		}
+
+		if found == nil {
+			found = info
+		}
+	}
+
+	if found == nil {
+		return &TransitionInfo[Action, State]{Name: action, Dst: to}
+	}
+
+	return found
+}
+
+// deferEvent keeps the action to be replayed later, once the caller is authorized to send it.
+func (fsk *FSM[Action, State, Param]) deferEvent(ctx context.Context, action Action, from, to State, param ...Param) error {
+	callbacks := callbacks[Action, State, Param]{Info: fsk.deferredInfo(action, to)}
+	if err := fsk.authorize(ctx, callbacks, action, from, to, param...); err != nil {
+		return err
+	}
+
+	fsk.deferred = append(fsk.deferred, deferredEvent[Action, State, Param]{
+		action:    action,
+		newState:  to,
+		params:    param,
+		principal: PrincipalFromContext(ctx),
+		scope:     callScopeFromContext[Action, State, Param](ctx),
+	})
+
+	item := fsk.newItem(ctx, action, from, to, param...)
+	item.Deferred = true
+
+	if errHistory := fsk.historyKeeper.PushItem(item, defaultSkipStackTrace); errHistory != nil {
+		return fmt.Errorf("failed to push history item: %w", errHistory)
+	}
+
+	return nil
+}
+
+// retryDeferred applies the deferred events that became valid after the last transition.
+//
+// An event stays deferred while the current state defers it, and expires as soon as the
+// machine reaches a state where the event is neither deferred nor valid. The replays run on behalf
+// of the callers who sent them, with their principal and call options. A replay that fails
+// is kept in the history and compensated, it doesn't fail the transition that unblocked it.
+func (fsk *FSM[Action, State, Param]) retryDeferred(ctx context.Context) error {
+	draining := fsk.draining
+	parentItemID := fsk.parentItemID
+	fsk.draining = true
+
+	defer func() {
+		fsk.draining = draining
+		fsk.parentItemID = parentItemID
+	}()
+
+	for progress := true; progress; {
+		progress = false
+
+		for index := 0; index < len(fsk.deferred); {
+			event := fsk.deferred[index]
+			currentState := fsk.currentState
+			fsk.parentItemID = fsk.unblockingItemID // the replays and expirations are caused by it
+
+			done := fsk.Done() // a final state expires whatever is deferred
+
+			if !done && fsk.hasTransition(event.action, currentState, event.newState) {
+				fsk.deferred = append(fsk.deferred[:index], fsk.deferred[index+1:]...)
+				fsk.replayDeferred = true
+				completedLength := len(fsk.completed)
+
+				err := fsk.applyAction(event.context(ctx), event.action, event.newState, event.params...)
+				if err != nil {
+					fsk.replayDeferred = false
+					fsk.raised = nil
+
+					if errCompensate := fsk.compensate(ctx, completedLength); errCompensate != nil {
+						return fmt.Errorf("failed to compensate deferred event %v: %w", event.action, errCompensate)
+					}
+				} else {
+					// the replay stays committed, a raised event that failed is kept in the history
+					// and compensated by itself
+					_ = fsk.drainRaised(ctx)
+				}
+
+				progress = true
+
+				break
+			}
+
+			if !done && fsk.isDeferredIn(event.action, currentState) {
+				index++
+
+				continue
+			}
+
+			fsk.deferred = append(fsk.deferred[:index], fsk.deferred[index+1:]...)
+
+			item := fsk.newItem(event.context(ctx), event.action, currentState, event.newState, event.params...)
+			item.Err = ErrExpired
+			item.Deferred = true
+
+			if errHistory := fsk.historyKeeper.PushItem(item, defaultSkipStackTrace); errHistory != nil {
+				return fmt.Errorf("failed to push history item: %w", errHistory)
+			}
+		}
+	}
+
+	return nil
+}
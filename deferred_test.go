@@ -0,0 +1,150 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_deferred_event_applied_when_valid(t *testing.T) {
+	const (
+		validating int = iota + 1
+		validated
+		paid
+	)
+
+	calledPay := 0
+
+	machine, _ := New(validating, []Transition[string, int, string]{
+		{Name: "validate", Src: []int{validating}, Dst: validated},
+		{
+			Name: "pay", Src: []int{validated}, Dst: paid,
+			DeferIn: []int{validating},
+			Enter: func(ctx context.Context, instance InstanceFSM[string, int, string], param string) error {
+				require.Equal(t, "card", param)
+				calledPay++
+
+				return nil
+			},
+		},
+	}, WithFullHistory[string]())
+
+	require.NoError(t, machine.Event(context.TODO(), "pay", "card"))
+	require.Equal(t, validating, machine.Current())
+	require.Equal(t, 0, calledPay)
+
+	require.NoError(t, machine.Event(context.TODO(), "validate"))
+	require.Equal(t, paid, machine.Current())
+	require.Equal(t, 1, calledPay)
+
+	expectedHistory := []HistoryItem[string, int, string]{
+		{Action: "pay", From: validating, To: paid, Params: []string{"card"}, Deferred: true, ID: 1},
+		{Action: "validate", From: validating, To: validated, Version: 1, ID: 2},
+		{Action: "pay", From: validated, To: paid, Params: []string{"card"}, Deferred: true, Version: 2, ID: 3, ParentID: 2},
+	}
+	require.Equal(t, expectedHistory, machine.History())
+}
+
+func Test_deferred_event_expired(t *testing.T) {
+	const (
+		validating int = iota + 1
+		validated
+		cancelled
+		paid
+	)
+
+	machine, _ := New(validating, []Transition[string, int, string]{
+		{Name: "validate", Src: []int{validating}, Dst: validated},
+		{Name: "cancel", Src: []int{validating}, Dst: cancelled},
+		{Name: "pay", Src: []int{validated}, Dst: paid, DeferIn: []int{validating}},
+	}, WithFullHistory[string]())
+
+	require.NoError(t, machine.Apply(context.TODO(), "pay", paid))
+	require.NoError(t, machine.Apply(context.TODO(), "cancel", cancelled))
+	require.Equal(t, cancelled, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 3)
+	require.True(t, history[2].Deferred)
+	require.Equal(t, cancelled, history[2].From)
+	require.ErrorIs(t, history[2].Err, ErrExpired)
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "pay", paid), ErrNotFound)
+}
+
+func Test_deferred_replay_failure_does_not_fail_the_trigger(t *testing.T) {
+	const (
+		validating int = iota + 1
+		validated
+		paid
+	)
+
+	errDeclined := errors.New("declined")
+
+	machine, _ := New(validating, []Transition[string, int, string]{
+		{Name: "validate", Src: []int{validating}, Dst: validated},
+		{
+			Name: "pay", Src: []int{validated}, Dst: paid,
+			DeferIn: []int{validating},
+			Enter: func(ctx context.Context, instance InstanceFSM[string, int, string], param string) error {
+				return errDeclined
+			},
+		},
+	}, WithFullHistory[string]())
+
+	require.NoError(t, machine.Event(context.TODO(), "pay", "card"))
+	require.NoError(t, machine.Event(context.TODO(), "validate"))
+	require.Equal(t, validated, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 3)
+	require.Equal(t, "pay", history[2].Action)
+	require.True(t, history[2].Deferred)
+	require.ErrorIs(t, history[2].Err, errDeclined)
+	require.Empty(t, machine.deferred)
+}
+
+func Test_deferred_by_failed_transition_is_discarded(t *testing.T) {
+	const (
+		validating int = iota + 1
+		validated
+		paid
+	)
+
+	type instance = InstanceFSM[string, int, string]
+
+	errExpected := errors.New("expected")
+	calledPay := 0
+
+	machine, _ := New(validating, []Transition[string, int, string]{
+		{
+			Name: "check", Src: []int{validating}, Dst: validating,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				if err := instance.Event(ctx, "pay", "card"); err != nil {
+					return err
+				}
+
+				return errExpected
+			},
+		},
+		{Name: "validate", Src: []int{validating}, Dst: validated},
+		{
+			Name: "pay", Src: []int{validated}, Dst: paid,
+			DeferIn: []int{validating},
+			Enter: func(ctx context.Context, instance instance, param string) error {
+				calledPay++
+
+				return nil
+			},
+		},
+	}, WithFullHistory[string]())
+
+	require.ErrorIs(t, machine.Event(context.TODO(), "check"), errExpected)
+	require.Empty(t, machine.deferred)
+
+	require.NoError(t, machine.Event(context.TODO(), "validate"))
+	require.Equal(t, validated, machine.Current())
+	require.Equal(t, 0, calledPay)
+}
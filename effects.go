@@ -0,0 +1,73 @@
+package kry
+
+import (
+	"context"
+	"fmt"
+)
+
+// EffectExecutor performs the side effects emitted by callbacks, once the transitions commit.
+//
+// The executor may be called again with the same effects if it fails, or after restoring
+// a snapshot with pending effects, so it should be idempotent.
+type EffectExecutor = func(ctx context.Context, effects []any) error
+
+// TypedEffectExecutor adapts an executor of effects of a single type,
+// it fails with ErrNotAllowed, without executing any effect, if an emitted effect is of another type.
+func TypedEffectExecutor[Effect any](executor func(ctx context.Context, effects []Effect) error) EffectExecutor {
+	return func(ctx context.Context, effects []any) error {
+		typed := make([]Effect, 0, len(effects))
+
+		for _, effect := range effects {
+			value, ok := effect.(Effect)
+			if !ok {
+				var zero Effect
+
+				return fmt.Errorf("effect %T isn't %T: %w", effect, zero, ErrNotAllowed)
+			}
+
+			typed = append(typed, value)
+		}
+
+		return executor(ctx, typed)
+	}
+}
+
+// Emit collects side effects from a callback instead of performing them right away.
+//
+// The effects are discarded if the transition fails or is ignored, and handed to the
+// executor set by WithEffectExecutor once the outermost transition commits.
+func (fsk *FSM[Action, State, Param]) Emit(effects ...any) {
+	fsk.effects = append(fsk.effects, effects...)
+}
+
+// FlushEffects hands the committed effects to the executor.
+//
+// If the executor fails, the effects stay pending, are kept in the snapshot,
+// and are retried on the next call. The error wraps ErrEffects.
+func (fsk *FSM[Action, State, Param]) FlushEffects(ctx context.Context) error {
+	if fsk.depth > 0 || fsk.tx != nil {
+		return nil // not committed yet
+	}
+
+	if fsk.effectExecutor == nil {
+		fsk.effects = nil
+		fsk.pendingEffects = nil
+
+		return nil
+	}
+
+	fsk.pendingEffects = append(fsk.pendingEffects, fsk.effects...)
+	fsk.effects = nil
+
+	if len(fsk.pendingEffects) == 0 {
+		return nil
+	}
+
+	if err := fsk.effectExecutor(ctx, fsk.pendingEffects); err != nil {
+		return fmt.Errorf("failed to execute %d effects: %w: %w", len(fsk.pendingEffects), ErrEffects, err)
+	}
+
+	fsk.pendingEffects = nil
+
+	return nil
+}
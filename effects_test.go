@@ -0,0 +1,178 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type sendEmail struct {
+	To string
+}
+
+func Test_effects_executed_after_commit(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	type instance = InstanceFSM[string, int, string]
+
+	errExpected := errors.New("expected error")
+	executed := []any{}
+
+	machine, _ := New(close, []Transition[string, int, string]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Enter: func(ctx context.Context, instance instance, param string) error {
+				instance.Emit(sendEmail{To: param})
+				require.Empty(t, executed)
+
+				if err := instance.Apply(ctx, "roger", roger, param); err != nil {
+					return err
+				}
+
+				if param == "fail" {
+					return errExpected
+				}
+
+				return nil
+			},
+		},
+		{
+			Name: "roger", Src: []int{open}, Dst: roger,
+			Enter: func(ctx context.Context, instance instance, param string) error {
+				instance.Emit("roger:" + param)
+
+				return nil
+			},
+		},
+		{
+			Name: "close", Src: []int{roger}, Dst: close,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				instance.Emit("ignored")
+				instance.IgnoreCurrentTransition()
+
+				return nil
+			},
+		},
+	}, WithEffectExecutor[string](func(ctx context.Context, effects []any) error {
+		executed = append(executed, effects...)
+
+		return nil
+	}))
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "open", open, "fail"), errExpected)
+	require.Empty(t, executed)
+
+	require.NoError(t, machine.Apply(context.TODO(), "open", open, "kry@example.com"))
+	require.Equal(t, []any{sendEmail{To: "kry@example.com"}, "roger:kry@example.com"}, executed)
+
+	require.NoError(t, machine.Apply(context.TODO(), "close", close))
+	require.Len(t, executed, 2)
+}
+
+func Test_effects_pending_kept_in_snapshot(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	errUnavailable := errors.New("smtp unavailable")
+	available := false
+	executed := []any{}
+
+	transitions := []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				instance.Emit(sendEmail{To: "kry@example.com"})
+
+				return nil
+			},
+		},
+	}
+	executor := WithEffectExecutor[any](func(ctx context.Context, effects []any) error {
+		if !available {
+			return errUnavailable
+		}
+
+		executed = append(executed, effects...)
+
+		return nil
+	})
+
+	machine, _ := New(close, transitions, executor)
+
+	err := machine.Apply(context.TODO(), "open", open)
+	require.ErrorIs(t, err, errUnavailable)
+	require.ErrorIs(t, err, ErrEffects) // committed, so not to be repeated
+	require.Equal(t, open, machine.Current())
+
+	snapshot := machine.Snapshot()
+	require.Equal(t, []any{sendEmail{To: "kry@example.com"}}, snapshot.PendingEffects)
+
+	available = true
+
+	restored, _ := New(close, transitions, executor)
+	require.NoError(t, restored.Restore(snapshot))
+	require.NoError(t, restored.FlushEffects(context.TODO()))
+	require.Equal(t, []any{sendEmail{To: "kry@example.com"}}, executed)
+	require.Empty(t, restored.Snapshot().PendingEffects)
+}
+
+func Test_effects_typed_executor(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	executed := []sendEmail{}
+
+	machine, _ := New(close, []Transition[string, int, string]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Enter: func(ctx context.Context, instance InstanceFSM[string, int, string], param string) error {
+				if param == "" {
+					instance.Emit("not an email")
+				}
+
+				instance.Emit(sendEmail{To: param})
+
+				return nil
+			},
+		},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithEffectExecutor[string](TypedEffectExecutor(func(ctx context.Context, effects []sendEmail) error {
+		executed = append(executed, effects...)
+
+		return nil
+	})))
+
+	err := machine.Apply(context.TODO(), "open", open, "")
+	require.ErrorIs(t, err, ErrEffects)
+	require.ErrorIs(t, err, ErrNotAllowed)
+	require.Empty(t, executed)
+	require.NotEmpty(t, machine.Snapshot().PendingEffects)
+
+	machine, _ = New(close, []Transition[string, int, string]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Enter: func(ctx context.Context, instance InstanceFSM[string, int, string], param string) error {
+				instance.Emit(sendEmail{To: param})
+
+				return nil
+			},
+		},
+	}, WithEffectExecutor[string](TypedEffectExecutor(func(ctx context.Context, effects []sendEmail) error {
+		executed = append(executed, effects...)
+
+		return nil
+	})))
+
+	require.NoError(t, machine.Apply(context.TODO(), "open", open, "kry@example.com"))
+	require.Equal(t, []sendEmail{{To: "kry@example.com"}}, executed)
+}
@@ -36,7 +36,11 @@ func main() {
 			Src:  []int{open},
 			Dst:  close,
 		},
-	}, kry.WithFullHistory[CustomParam]())
+	}, kry.WithFullHistory[CustomParam](), kry.WithStates[CustomParam](map[int]kry.StateInfo{
+		initial: {Name: "Initial"},
+		close:   {Name: "Closed"},
+		open:    {Name: "Opened"},
+	}))
 	if err != nil {
 		panic(err)
 	}
@@ -45,11 +49,11 @@ func main() {
 		panic(err)
 	}
 
-	fmt.Println("Current state:", fsk.Current())
+	fmt.Println("Current state:", fsk.StateName(fsk.Current()))
 
 	if err := fsk.Apply(ctx, "close", close); err != nil {
 		panic(err)
 	}
 
-	fmt.Println("Current state:", fsk.Current())
+	fmt.Println("Current state:", fsk.StateName(fsk.Current()))
 }
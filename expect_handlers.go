@@ -1,15 +1,29 @@
 package kry
 
 import (
+	"context"
 	"reflect"
 )
 
-func (fsk *FSM[Action, State, Param]) checkCallbacksAgainstExpectHandlers(callbacks callbacks[Action, State, Param]) bool {
-	if fsk.decoratorApply != nil {
-		if len(fsk.decoratorApply.expectToCallEnter) > 0 {
+func (fsk *FSM[Action, State, Param]) checkCallbacksAgainstExpectHandlers(
+	ctx context.Context,
+	callbacks callbacks[Action, State, Param],
+) bool {
+	decorator := fsk.decoratorApply
+
+	// the transition of an ApplyWith/EventWith call only checks its own expectations,
+	// the sticky ones are left to the next transition
+	if scope := callScopeFromContext[Action, State, Param](ctx); scope != nil && !scope.started {
+		decorator = scope.expect
+		scope.expect = nil
+		scope.started = true
+	}
+
+	if decorator != nil {
+		if len(decorator.expectToCallEnter) > 0 {
 			expectedEnterFound := false
-			expectToCallEnter := fsk.decoratorApply.expectToCallEnter
-			fsk.decoratorApply.expectToCallEnter = nil
+			expectToCallEnter := decorator.expectToCallEnter
+			decorator.expectToCallEnter = nil
 
 			pointerToEnter := reflect.ValueOf(callbacks.Enter).Pointer()
 			for _, expectedHandler := range expectToCallEnter {
@@ -23,10 +37,10 @@ func (fsk *FSM[Action, State, Param]) checkCallbacksAgainstExpectHandlers(callba
 			return !expectedEnterFound
 		}
 
-		if len(fsk.decoratorApply.expectToCallEnterNoParams) > 0 {
+		if len(decorator.expectToCallEnterNoParams) > 0 {
 			expectedEnterNoParamsFound := false
-			expectToCallEnterNoParams := fsk.decoratorApply.expectToCallEnterNoParams
-			fsk.decoratorApply.expectToCallEnterNoParams = nil
+			expectToCallEnterNoParams := decorator.expectToCallEnterNoParams
+			decorator.expectToCallEnterNoParams = nil
 
 			pointerToEnterNoParams := reflect.ValueOf(callbacks.EnterNoParams).Pointer()
 			for _, expectedHandler := range expectToCallEnterNoParams {
@@ -40,10 +54,10 @@ func (fsk *FSM[Action, State, Param]) checkCallbacksAgainstExpectHandlers(callba
 			return !expectedEnterNoParamsFound
 		}
 
-		if len(fsk.decoratorApply.expectToCallEnterVariadic) > 0 {
+		if len(decorator.expectToCallEnterVariadic) > 0 {
 			expectedEnterVariadicFound := false
-			expectToCallEnterVariadic := fsk.decoratorApply.expectToCallEnterVariadic
-			fsk.decoratorApply.expectToCallEnterVariadic = nil
+			expectToCallEnterVariadic := decorator.expectToCallEnterVariadic
+			decorator.expectToCallEnterVariadic = nil
 
 			pointerToEnterVariadic := reflect.ValueOf(callbacks.EnterVariadic).Pointer()
 			for _, expectedHandler := range expectToCallEnterVariadic {
@@ -44,11 +44,15 @@ func Test_option_expect_enter_handler_ok(t *testing.T) {
 			From:         close,
 			To:           open,
 			ExpectFailed: true,
+			Version:      1,
+			ID:           1,
 		},
 		{
-			Action: "close",
-			From:   open,
-			To:     close,
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Version: 2,
+			ID:      2,
 		},
 	}
 
@@ -102,11 +106,15 @@ func Test_option_expect_enter_no_params_handler_ok(t *testing.T) {
 			From:         close,
 			To:           open,
 			ExpectFailed: true,
+			Version:      1,
+			ID:           1,
 		},
 		{
-			Action: "close",
-			From:   open,
-			To:     close,
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Version: 2,
+			ID:      2,
 		},
 	}
 
@@ -160,11 +168,15 @@ func Test_option_expect_enter_variadic_handler_ok(t *testing.T) {
 			From:         close,
 			To:           open,
 			ExpectFailed: true,
+			Version:      1,
+			ID:           1,
 		},
 		{
-			Action: "close",
-			From:   open,
-			To:     close,
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Version: 2,
+			ID:      2,
 		},
 	}
 
@@ -235,16 +247,24 @@ func Test_option_expect_level2_enter_handler_case1_ok(t *testing.T) {
 			To:           open,
 			Params:       []string{"goto-roger"},
 			ExpectFailed: true,
+			Version:      2,
+			ID:           1,
 		},
 		{
-			Action: "roger",
-			From:   open,
-			To:     roger,
+			Action:   "roger",
+			From:     open,
+			To:       roger,
+			Version:  1,
+			Depth:    1,
+			ID:       2,
+			ParentID: 1,
 		},
 		{
-			Action: "close",
-			From:   roger,
-			To:     close,
+			Action:  "close",
+			From:    roger,
+			To:      close,
+			Version: 3,
+			ID:      3,
 		},
 	}
 
@@ -322,17 +342,25 @@ func Test_option_expect_level2_enter_handler_case2_ok(t *testing.T) {
 			To:           open,
 			Params:       []string{"goto-roger"},
 			ExpectFailed: true,
+			Version:      2,
+			ID:           1,
 		},
 		{
 			Action:       "roger",
 			From:         open,
 			To:           roger,
 			ExpectFailed: true,
+			Version:      1,
+			Depth:        1,
+			ID:           2,
+			ParentID:     1,
 		},
 		{
-			Action: "close",
-			From:   roger,
-			To:     close,
+			Action:  "close",
+			From:    roger,
+			To:      close,
+			Version: 3,
+			ID:      3,
 		},
 	}
 
@@ -405,21 +433,29 @@ func Test_option_expect_level2_enter_handler_case3_ok(t *testing.T) {
 
 	expectedHistory := []HistoryItem[string, int, string]{
 		{
-			Action: "open",
-			From:   close,
-			To:     open,
-			Params: []string{"goto-roger"},
+			Action:  "open",
+			From:    close,
+			To:      open,
+			Params:  []string{"goto-roger"},
+			Version: 2,
+			ID:      1,
 		},
 		{
 			Action:       "roger",
 			From:         open,
 			To:           roger,
 			ExpectFailed: true,
+			Version:      1,
+			Depth:        1,
+			ID:           2,
+			ParentID:     1,
 		},
 		{
-			Action: "close",
-			From:   roger,
-			To:     close,
+			Action:  "close",
+			From:    roger,
+			To:      close,
+			Version: 3,
+			ID:      3,
 		},
 	}
 
@@ -491,20 +527,28 @@ func Test_option_expect_level2_enter_handler_case4_ok(t *testing.T) {
 
 	expectedHistory := []HistoryItem[string, int, string]{
 		{
-			Action: "open",
-			From:   close,
-			To:     open,
-			Params: []string{"goto-roger"},
+			Action:  "open",
+			From:    close,
+			To:      open,
+			Params:  []string{"goto-roger"},
+			Version: 2,
+			ID:      1,
 		},
 		{
-			Action: "roger",
-			From:   open,
-			To:     roger,
+			Action:   "roger",
+			From:     open,
+			To:       roger,
+			Version:  1,
+			Depth:    1,
+			ID:       2,
+			ParentID: 1,
 		},
 		{
-			Action: "close",
-			From:   roger,
-			To:     close,
+			Action:  "close",
+			From:    roger,
+			To:      close,
+			Version: 3,
+			ID:      3,
 		},
 	}
 
@@ -0,0 +1,179 @@
+package kry
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Done reports whether the machine is in a final state, see WithFinalStates and StateInfo.Final.
+func (fsk *FSM[Action, State, Param]) Done() bool {
+	_, ok := fsk.finalStates[fsk.currentState]
+
+	return ok
+}
+
+// Completed returns a channel that is closed once the machine commits a final state.
+//
+// Leaving the final state by ForceState or Restore gives a new channel for the next completion.
+func (fsk *FSM[Action, State, Param]) Completed() <-chan struct{} {
+	return fsk.completedCh
+}
+
+// WithParent raises the action on the parent machine when the machine reaches a final state,
+// the way a hierarchical machine learns that its child completed.
+func WithParent[Param any, ParentAction, ParentState comparable, ParentParam any](
+	parent *FSM[ParentAction, ParentState, ParentParam],
+	action ParentAction,
+	param ...ParentParam,
+) func(o *Options[Param]) *Options[Param] {
+	return WithOnCompleted[Param](func(ctx context.Context) error {
+		return parent.Raise(ctx, action, param...)
+	})
+}
+
+func constructFinalStates[State comparable](
+	finalStates any,
+	stateInfos map[State]StateInfo,
+	states map[State]struct{},
+) (map[State]struct{}, error) {
+	result := map[State]struct{}{}
+
+	if finalStates != nil {
+		list, ok := finalStates.([]State)
+		if !ok {
+			return nil, fmt.Errorf("final states %T don't match the machine: %w", finalStates, ErrNotAllowed)
+		}
+
+		for _, state := range list {
+			if _, ok := states[state]; !ok {
+				return nil, fmt.Errorf("final state %w: %v", ErrUnknown, state)
+			}
+
+			result[state] = struct{}{}
+		}
+	}
+
+	for state, info := range stateInfos {
+		if info.Final {
+			result[state] = struct{}{}
+		}
+	}
+
+	return result, nil
+}
+
+// syncCompleted closes the completion channel if the machine is done, or renews it if the machine
+// left the final state. It returns true if the channel was closed by this call.
+func (fsk *FSM[Action, State, Param]) syncCompleted() bool {
+	if !fsk.Done() {
+		if fsk.completedClosed {
+			fsk.completedCh = make(chan struct{})
+			fsk.completedClosed = false
+		}
+
+		return false
+	}
+
+	if fsk.completedClosed {
+		return false
+	}
+
+	close(fsk.completedCh)
+	fsk.completedClosed = true
+
+	return true
+}
+
+// complete signals the completion once the outermost transition commits a final state.
+func (fsk *FSM[Action, State, Param]) complete(ctx context.Context) error {
+	if !fsk.syncCompleted() {
+		return nil
+	}
+
+	for _, onCompleted := range fsk.onCompleted {
+		if err := onCompleted(ctx); err != nil {
+			return fmt.Errorf("completed in state %v: %w", fsk.currentState, err)
+		}
+	}
+
+	return nil
+}
+
+type IssueKind string
+
+const (
+	IssueDeadEnd        IssueKind = "dead end"         // a non-final state without outgoing transitions
+	IssueFinalWithExits IssueKind = "final with exits" // a final state with outgoing transitions
+)
+
+// Issue is a problem found by Analyze.
+type Issue[State comparable] struct {
+	State State
+	Kind  IssueKind
+}
+
+func (issue Issue[State]) String() string {
+	return fmt.Sprintf("state %v: %s", issue.State, issue.Kind)
+}
+
+// Analyze checks the transitions of the machine against its final states,
+// the issues are sorted by state.
+func (fsk *FSM[Action, State, Param]) Analyze() []Issue[State] {
+	issues := []Issue[State]{}
+
+	for state := range fsk.states {
+		_, final := fsk.finalStates[state]
+		outgoing := fsk.hasOutgoing(state)
+
+		switch {
+		case !final && !outgoing:
+			issues = append(issues, Issue[State]{State: state, Kind: IssueDeadEnd})
+		case final && outgoing:
+			issues = append(issues, Issue[State]{State: state, Kind: IssueFinalWithExits})
+		}
+	}
+
+	slices.SortFunc(issues, func(a, b Issue[State]) int {
+		return cmp.Compare(fmt.Sprint(a.State), fmt.Sprint(b.State))
+	})
+
+	return issues
+}
+
+func (fsk *FSM[Action, State, Param]) hasOutgoing(state State) bool {
+	for _, byDst := range fsk.path {
+		for _, bySrc := range byDst {
+			if _, ok := bySrc[state]; ok {
+				return true
+			}
+		}
+	}
+
+	for _, bySrc := range fsk.pathByMatchDst {
+		if _, ok := bySrc[state]; ok {
+			return true
+		}
+	}
+
+	for _, byDst := range fsk.pathByMatchSrc {
+		for _, matches := range byDst {
+			for _, match := range matches {
+				if match.MatchSrc(state) {
+					return true
+				}
+			}
+		}
+	}
+
+	for _, matches := range fsk.pathMatch {
+		for _, match := range matches {
+			if match.MatchSrc(state) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
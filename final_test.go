@@ -0,0 +1,107 @@
+package kry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_final_state_done_and_completed(t *testing.T) {
+	const (
+		pending int = iota + 1
+		paid
+		shipped
+	)
+
+	completions := 0
+
+	machine, err := New(pending, []Transition[string, int, any]{
+		{Name: "pay", Src: []int{pending}, Dst: paid},
+		{Name: "ship", Src: []int{paid}, Dst: shipped},
+	}, WithFinalStates[any](shipped), WithOnCompleted[any](func(ctx context.Context) error {
+		completions++
+
+		return nil
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "pay"))
+	require.False(t, machine.Done())
+
+	select {
+	case <-machine.Completed():
+		require.Fail(t, "not completed yet")
+	default:
+	}
+
+	require.NoError(t, machine.Event(context.TODO(), "ship"))
+	require.True(t, machine.Done())
+	require.Equal(t, 1, completions)
+
+	<-machine.Completed()
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "pay", paid), ErrDone)
+
+	// leaving the final state gives a new completion
+	require.NoError(t, machine.ForceState(context.TODO(), paid, "refund"))
+	require.False(t, machine.Done())
+
+	select {
+	case <-machine.Completed():
+		require.Fail(t, "not completed yet")
+	default:
+	}
+
+	require.NoError(t, machine.Event(context.TODO(), "ship"))
+	require.Equal(t, 2, completions)
+	<-machine.Completed()
+
+	_, err = New(pending, []Transition[string, int, any]{
+		{Name: "pay", Src: []int{pending}, Dst: paid},
+	}, WithFinalStates[any](shipped))
+	require.ErrorIs(t, err, ErrUnknown)
+}
+
+func Test_final_state_raises_on_parent(t *testing.T) {
+	const (
+		idle int = iota + 1
+		running
+		finished
+	)
+
+	parent, _ := New(idle, []Transition[string, int, any]{
+		{Name: "start", Src: []int{idle}, Dst: running},
+		{Name: "child_done", Src: []int{running}, Dst: finished},
+	}, WithRunToCompletion[any]())
+
+	child, _ := New(idle, []Transition[string, int, any]{
+		{Name: "work", Src: []int{idle}, Dst: finished},
+	}, WithStates[any](map[int]StateInfo{finished: {Final: true}}), WithParent[any](parent, "child_done"))
+
+	require.NoError(t, parent.Event(context.TODO(), "start"))
+	require.NoError(t, child.Event(context.TODO(), "work"))
+	require.True(t, child.Done())
+	require.Equal(t, finished, parent.Current())
+}
+
+func Test_analyze_final_states(t *testing.T) {
+	const (
+		draft int = iota + 1
+		review
+		rejected
+		published
+	)
+
+	machine, _ := New(draft, []Transition[string, int, any]{
+		{Name: "submit", Src: []int{draft}, Dst: review},
+		{Name: "reject", Src: []int{review}, Dst: rejected},
+		{Name: "publish", Src: []int{review}, Dst: published},
+		{Name: "unpublish", Src: []int{published}, Dst: draft},
+	}, WithFinalStates[any](published))
+
+	require.Equal(t, []Issue[int]{
+		{State: rejected, Kind: IssueDeadEnd},
+		{State: published, Kind: IssueFinalWithExits},
+	}, machine.Analyze())
+}
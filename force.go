@@ -0,0 +1,93 @@
+package kry
+
+import (
+	"context"
+	"fmt"
+)
+
+func constructForceTargets[State comparable](
+	forceTargets any,
+	states map[State]struct{},
+) (map[State]struct{}, error) {
+	if forceTargets == nil {
+		return nil, nil
+	}
+
+	list, ok := forceTargets.([]State)
+	if !ok {
+		return nil, fmt.Errorf("force targets %T don't match the machine: %w", forceTargets, ErrNotAllowed)
+	}
+
+	result := map[State]struct{}{}
+	for _, state := range list {
+		if _, ok := states[state]; !ok {
+			return nil, fmt.Errorf("force target %w: %v", ErrUnknown, state)
+		}
+
+		result[state] = struct{}{}
+	}
+
+	return result, nil
+}
+
+// ForceState moves the machine to newState without applying any transition.
+//
+// Every call is kept in the history as a Forced item with the reason and the principal from ctx,
+// the refused ones included, and the state isn't forced if the item can't be kept. It can be disabled
+// by WithoutForceState, or restricted to some states by WithForceStateTargets.
+//
+// Forcing a final state completes the machine as a transition would, running the WithOnCompleted
+// hooks and notifying the parent.
+//
+// Called from a callback, the forced state is reverted along with the transition if it fails
+// or is ignored, and its item is flagged as RolledBack.
+func (fsk *FSM[Action, State, Param]) ForceState(ctx context.Context, newState State, reason string) error {
+	var action Action
+
+	item := fsk.newItem(ctx, action, fsk.currentState, newState)
+	item.Forced = true
+	item.ForceReason = reason
+	item.Err = fsk.checkForce(newState)
+
+	if item.Err == nil {
+		item.Version = fsk.version + 1
+	}
+
+	if errHistory := fsk.historyKeeper.PushItem(item, defaultSkipStackTrace); errHistory != nil {
+		return fmt.Errorf("force state to '%v' not applied: %w", newState, errHistory)
+	}
+
+	if item.Err == nil {
+		fsk.previousState = fsk.currentState
+		fsk.currentState = newState
+		fsk.version++
+	}
+
+	if fsk.depth == 0 {
+		defer fsk.settleHistory()
+
+		if item.Err == nil {
+			return fsk.complete(ctx) // a forced final state notifies WithOnCompleted and the parent too
+		}
+	}
+
+	return item.Err
+}
+
+func (fsk *FSM[Action, State, Param]) checkForce(newState State) error {
+	if _, ok := fsk.states[newState]; !ok {
+		return fmt.Errorf("state %w: %v", ErrUnknown, newState)
+	}
+
+	if fsk.forceDisabled {
+		return fmt.Errorf("force state to '%v' is disabled: %w", newState, ErrNotAllowed)
+	}
+
+	if fsk.forceTargets != nil {
+		if _, ok := fsk.forceTargets[newState]; !ok {
+			return fmt.Errorf("force state to '%v' isn't allowed: %w", newState, ErrNotAllowed)
+		}
+	}
+
+	return nil
+}
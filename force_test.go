@@ -0,0 +1,230 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_force_state_is_kept_in_history(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		broken
+	)
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "break", Src: []int{open}, Dst: broken},
+	}, WithFullHistory[any]())
+	require.NoError(t, err)
+
+	ctx := ContextWithPrincipal(context.TODO(), "alice")
+
+	require.NoError(t, machine.Event(ctx, "open"))
+	require.NoError(t, machine.ForceState(ctx, broken, "stuck since the outage"))
+	require.Equal(t, broken, machine.Current())
+	require.Equal(t, open, machine.Previous())
+
+	require.ErrorIs(t, machine.ForceState(ctx, 42, "typo"), ErrUnknown)
+	require.Equal(t, broken, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 3)
+	require.Equal(t, HistoryItem[string, int, any]{
+		From: open, To: broken, Forced: true, ForceReason: "stuck since the outage",
+		Principal: "alice", Version: 2, ID: 2,
+	}, history[1])
+	require.True(t, history[2].Forced)
+	require.ErrorIs(t, history[2].Err, ErrUnknown)
+	require.Equal(t, uint64(2), history[2].Version)
+}
+
+func Test_force_state_disabled(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+	}, WithFullHistory[any](), WithoutForceState[any]())
+	require.NoError(t, err)
+
+	ctx := ContextWithPrincipal(context.TODO(), "mallory")
+
+	require.ErrorIs(t, machine.ForceState(ctx, open, "shortcut"), ErrNotAllowed)
+	require.Equal(t, close, machine.Current())
+	require.Equal(t, uint64(0), machine.Version())
+
+	history := machine.History()
+	require.Len(t, history, 1)
+	require.True(t, history[0].Forced)
+	require.Equal(t, "mallory", history[0].Principal)
+	require.ErrorIs(t, history[0].Err, ErrNotAllowed)
+}
+
+func Test_force_state_targets(t *testing.T) {
+	const (
+		idle int = iota + 1
+		running
+		failed
+	)
+
+	machine, err := New(idle, []Transition[string, int, any]{
+		{Name: "run", Src: []int{idle}, Dst: running},
+		{Name: "fail", Src: []int{running}, Dst: failed},
+		{Name: "reset", Src: []int{failed}, Dst: idle},
+	}, WithForceStateTargets[any](idle, failed))
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "run"))
+	require.ErrorIs(t, machine.ForceState(context.TODO(), running, "retry"), ErrNotAllowed)
+	require.NoError(t, machine.ForceState(context.TODO(), failed, "worker lost"))
+	require.NoError(t, machine.ForceState(context.TODO(), idle, "reset by operator"))
+	require.Equal(t, idle, machine.Current())
+
+	_, err = New(idle, []Transition[string, int, any]{
+		{Name: "run", Src: []int{idle}, Dst: running},
+	}, WithForceStateTargets[any](failed))
+	require.ErrorIs(t, err, ErrUnknown)
+
+	_, err = New(idle, []Transition[string, int, any]{
+		{Name: "run", Src: []int{idle}, Dst: running},
+	}, WithForceStateTargets[any]("idle"))
+	require.ErrorIs(t, err, ErrNotAllowed)
+}
+
+func Test_force_state_in_callback_is_nested(t *testing.T) {
+	const (
+		close int = iota + 1
+		roger
+		open
+	)
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.ForceState(ctx, roger, "redirected")
+			},
+		},
+		{Name: "close", Src: []int{open, roger}, Dst: close},
+	}, WithFullHistory[any]())
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+
+	history := machine.History()
+	require.Len(t, history, 2)
+
+	require.Equal(t, roger, machine.Current())
+	require.False(t, history[0].Forced)
+	require.True(t, history[1].Forced)
+	require.Equal(t, "redirected", history[1].ForceReason)
+	require.Equal(t, 1, history[1].Depth)
+	require.Equal(t, history[0].ID, history[1].ParentID)
+}
+
+func Test_force_state_not_applied_if_not_kept(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	errClone := errors.New("clone")
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+	}, WithFullHistory[any](), WithCloneHandler(func(params ...any) ([]any, error) {
+		return nil, errClone
+	}))
+	require.NoError(t, err)
+
+	require.ErrorIs(t, machine.ForceState(context.TODO(), open, "manual"), errClone)
+	require.Equal(t, close, machine.Current())
+	require.Equal(t, uint64(0), machine.Version())
+}
+
+func Test_force_state_reverted_by_failed_transition(t *testing.T) {
+	const (
+		close int = iota + 1
+		roger
+		open
+	)
+
+	errExpected := errors.New("expected")
+	items := []HistoryItem[string, int, any]{}
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				if err := instance.ForceState(ctx, roger, "redirected"); err != nil {
+					return err
+				}
+
+				return errExpected
+			},
+		},
+		{Name: "close", Src: []int{open, roger}, Dst: close},
+	}, WithFullHistory[any](), WithHistorySink[any](func(item HistoryItem[string, int, any]) error {
+		items = append(items, item)
+
+		return nil
+	}))
+	require.NoError(t, err)
+
+	require.ErrorIs(t, machine.Event(context.TODO(), "open"), errExpected)
+	require.Equal(t, close, machine.Current())
+	require.Equal(t, uint64(0), machine.Version())
+
+	history := machine.History()
+	require.Len(t, history, 2)
+	require.ErrorIs(t, history[0].Err, errExpected)
+	require.True(t, history[1].Forced)
+	require.True(t, history[1].RolledBack)
+	require.Equal(t, history, items)
+}
+
+func Test_force_state_into_final_state_completes(t *testing.T) {
+	const (
+		idle int = iota + 1
+		running
+		finished
+	)
+
+	parent, _ := New(idle, []Transition[string, int, any]{
+		{Name: "start", Src: []int{idle}, Dst: running},
+		{Name: "child_done", Src: []int{running}, Dst: finished},
+	})
+
+	hooks := 0
+
+	child, _ := New(idle, []Transition[string, int, any]{
+		{Name: "work", Src: []int{idle}, Dst: finished},
+	},
+		WithStates[any](map[int]StateInfo{finished: {Final: true}}),
+		WithParent[any](parent, "child_done"),
+		WithOnCompleted[any](func(ctx context.Context) error {
+			hooks++
+
+			return nil
+		}),
+	)
+
+	require.NoError(t, parent.Event(context.TODO(), "start"))
+	require.NoError(t, child.ForceState(context.TODO(), finished, "manual fix"))
+
+	require.True(t, child.Done())
+	require.Equal(t, 1, hooks)
+	require.Equal(t, finished, parent.Current())
+
+	select {
+	case <-child.Completed():
+	default:
+		require.Fail(t, "completed isn't closed")
+	}
+}
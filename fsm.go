@@ -3,6 +3,7 @@ package kry
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 type errString string
@@ -20,17 +21,34 @@ const (
 
 	ErrLoopFound  errString = "loop found"
 	ErrNotAllowed errString = "not allowed"
+	ErrStopped    errString = "stopped"
+	ErrExpired    errString = "expired"
+	ErrRolledBack errString = "rolled back"
+	ErrTimeout    errString = "timeout"
+
+	ErrVersionMismatch errString = "version mismatch"
+	ErrDone            errString = "done"
+	ErrTampered        errString = "tampered"
+	ErrEffects         errString = "effects failed"
+	ErrRaised          errString = "raised event failed"
 )
 
 type InstanceFSM[Action, State comparable, Param any] interface {
 	Current() State
 	Previous() State
+	Data() any
+	SetData(data any)
 
 	With(opts ...func(fsk InstanceFSM[Action, State, Param]) InstanceFSM[Action, State, Param]) InstanceFSM[Action, State, Param]
 	Event(ctx context.Context, action Action, param ...Param) error
 	Apply(ctx context.Context, action Action, newState State, param ...Param) error
+	EventWith(ctx context.Context, options CallOptions[Action, State, Param], action Action, param ...Param) error
+	ApplyWith(ctx context.Context, options CallOptions[Action, State, Param], action Action, newState State, param ...Param) error
 
-	ForceState(newState State) error
+	Raise(ctx context.Context, action Action, param ...Param) error
+	Emit(effects ...any)
+
+	ForceState(ctx context.Context, newState State, reason string) error
 	IgnoreCurrentTransition()
 }
 
@@ -41,6 +59,10 @@ type callbacks[Action, State comparable, Param any] struct {
 	EnterNoParams handlerNoParams[Action, State, Param]
 	Enter         handler[Action, State, Param]
 	EnterVariadic handlerVariadic[Action, State, Param]
+	Compensate    handlerVariadic[Action, State, Param]
+	Retry         *RetryPolicy
+	Timeout       time.Duration
+	Info          *TransitionInfo[Action, State]
 }
 
 // Transition contains the name of the action, the source states, the destination state,
@@ -52,9 +74,28 @@ type Transition[Action, State comparable, Param any] struct {
 	Dst   State
 	DstFn func(state State) bool // optional custom matching function for destination states
 
+	DeferIn []State // optional states where the action is deferred instead of rejected
+
 	EnterNoParams handlerNoParams[Action, State, Param]
 	Enter         handler[Action, State, Param]
 	EnterVariadic handlerVariadic[Action, State, Param]
+
+	// Compensate is called to undo the side effects of the transition
+	// when a chain it completed in fails later on.
+	Compensate handlerVariadic[Action, State, Param]
+
+	Retry *RetryPolicy // optional, overrides the policy given by WithRetryPolicy
+
+	// Timeout optionally bounds the Enter* callback, it's checked for every attempt.
+	// An expired deadline is handled as a callback error that wraps ErrTimeout.
+	Timeout time.Duration
+
+	Roles []string // optional roles required by the action, see RequiredRoles
+
+	// optional human-facing information, kept in the history and shown by the visualization
+	Description string
+	Tags        []string
+	Meta        map[string]any
 }
 
 type matchState[Action, State comparable, Param any] struct {
@@ -74,8 +115,10 @@ type FSM[Action, State comparable, Param any] struct {
 	currentAction Action
 	currentState  State
 	previousState State
+	data          any
 	ignoreCurrent bool
-	runningApply  bool
+	depth         int  // how many apply calls are running, nested ones included
+	draining      bool // true while raised events are being processed
 
 	states         map[State]struct{}
 	path           map[Action]map[State]map[State]callbacks[Action, State, Param] // action -> dst state -> src state -> callbacks
@@ -91,6 +134,34 @@ type FSM[Action, State comparable, Param any] struct {
 	stackTrace       bool
 	panicHandler     PanicHandler
 	cloneHandler     CloneHandler[Param]
+	runToCompletion  bool
+	raised           []raisedEvent[Action, Param]
+	deferIn          map[Action]map[State]struct{} // action -> states where it's deferred
+	deferred         []deferredEvent[Action, State, Param]
+	replayDeferred   bool // true when the next apply replays a deferred event
+	tx               *transaction
+	completed        []completedStep[Action, State, Param] // steps to compensate if the chain fails
+	effects          []any                                 // effects emitted by the running chain
+	pendingEffects   []any                                 // committed effects not executed yet
+	effectExecutor   EffectExecutor
+	retryPolicy      *RetryPolicy
+	clock            Clock
+	idempotency      *idempotencyCache[State]
+	idempotentErrors func(err error) bool
+	version          uint64
+	authorizer       Authorizer[Action, State]
+	transitions      []TransitionInfo[Action, State]
+	stateInfos       map[State]StateInfo
+	finalStates      map[State]struct{}
+	completedCh      chan struct{}
+	completedClosed  bool
+	onCompleted      []func(ctx context.Context) error
+	timings          bool
+	lastItemID       uint64
+	parentItemID     uint64 // ID of the history item of the transition being applied
+	unblockingItemID uint64 // ID of the history item of the last outermost transition applied
+	forceDisabled    bool
+	forceTargets     map[State]struct{} // nil if ForceState may target any state
 }
 
 // New creates a new FSM instance with the given initial state, transitions, and options.
@@ -112,6 +183,36 @@ func New[Action, State comparable, Param any](
 		finalOptions.cloneHandler = cloneHandler[Param]
 	}
 
+	if finalOptions.idempotentErrs == nil {
+		finalOptions.idempotentErrs = IsDeterministicError
+	}
+
+	if finalOptions.clock == nil {
+		finalOptions.clock = systemClock{}
+	}
+
+	if finalOptions.retention.KeepErrors && finalOptions.hashChain {
+		return nil, fmt.Errorf("retention keeping the errors would leave gaps in the hash chain: %w", ErrNotAllowed)
+	}
+
+	var authorizer Authorizer[Action, State]
+	if finalOptions.authorizer != nil {
+		var ok bool
+		if authorizer, ok = finalOptions.authorizer.(Authorizer[Action, State]); !ok {
+			return nil, fmt.Errorf("authorizer %T doesn't match the machine: %w",
+				finalOptions.authorizer, ErrNotAllowed)
+		}
+	}
+
+	var historySink HistorySink[Action, State, Param]
+	if finalOptions.historySink != nil {
+		var ok bool
+		if historySink, ok = finalOptions.historySink.(HistorySink[Action, State, Param]); !ok {
+			return nil, fmt.Errorf("history sink %T doesn't match the machine: %w",
+				finalOptions.historySink, ErrNotAllowed)
+		}
+	}
+
 	path, pathByMatchSrc, pathByMatchDst, pathMatch, states, events,
 		canTriggerEvents, err := constructFromTransitions(initialState, transitions)
 	if err != nil {
@@ -122,13 +223,30 @@ func New[Action, State comparable, Param any](
 		events = nil
 	}
 
+	stateInfos, err := constructStateInfos(finalOptions.stateInfos, states)
+	if err != nil {
+		return nil, err
+	}
+
+	finalStates, err := constructFinalStates(finalOptions.finalStates, stateInfos, states)
+	if err != nil {
+		return nil, err
+	}
+
+	forceTargets, err := constructForceTargets(finalOptions.forceTargets, states)
+	if err != nil {
+		return nil, err
+	}
+
 	idMachine++
 
-	graphic := fmt.Sprintf("digraph fsm_%d {\n%s\n}", idMachine, VisualizeActions(transitions))
+	graphic := fmt.Sprintf("digraph fsm_%d {\n%s%s\n}", idMachine,
+		VisualizeStates(stateInfos), VisualizeActions(transitions))
 
-	return &FSM[Action, State, Param]{
+	fsk := &FSM[Action, State, Param]{
 		id:             idMachine,
 		currentState:   initialState,
+		data:           finalOptions.data,
 		path:           path,
 		pathByMatchSrc: pathByMatchSrc,
 		pathByMatchDst: pathByMatchDst,
@@ -143,10 +261,41 @@ func New[Action, State comparable, Param any](
 			finalOptions.stackTrace,
 			finalOptions.cloneHandler,
 		),
-		stackTrace:   finalOptions.stackTrace,
-		panicHandler: finalOptions.panicHandler,
-		cloneHandler: finalOptions.cloneHandler,
-	}, nil
+		stackTrace:       finalOptions.stackTrace,
+		panicHandler:     finalOptions.panicHandler,
+		cloneHandler:     finalOptions.cloneHandler,
+		runToCompletion:  finalOptions.runToCompletion,
+		deferIn:          constructDeferIn(transitions),
+		effectExecutor:   finalOptions.effectExecutor,
+		retryPolicy:      finalOptions.retryPolicy,
+		clock:            finalOptions.clock,
+		idempotency:      newIdempotencyCache[State](finalOptions.idempotencySize),
+		idempotentErrors: finalOptions.idempotentErrs,
+		authorizer:       authorizer,
+		transitions:      newTransitionInfos(transitions),
+		stateInfos:       stateInfos,
+		finalStates:      finalStates,
+		completedCh:      make(chan struct{}),
+		onCompleted:      finalOptions.onCompleted,
+		timings:          finalOptions.timings,
+		forceDisabled:    finalOptions.forceDisabled,
+		forceTargets:     forceTargets,
+	}
+
+	fsk.historyKeeper.historySettings = historySettings[Action, State, Param]{
+		sink:          historySink,
+		redactor:      finalOptions.redactor,
+		errorRedactor: finalOptions.errorRedactor,
+		callRedactor:  finalOptions.callRedactor,
+		dataRedactor:  finalOptions.dataRedactor,
+		onSinkError:   finalOptions.onSinkError,
+		hashChain:     finalOptions.hashChain,
+		retention:     finalOptions.retention,
+		clock:         finalOptions.clock,
+	}
+	fsk.syncCompleted()
+
+	return fsk, nil
 }
 
 func (fsk *FSM[Action, State, Param]) String() string {
@@ -161,20 +310,21 @@ func (fsk *FSM[Action, State, Param]) Previous() State {
 	return fsk.previousState
 }
 
-func (fsk *FSM[Action, State, Param]) ForceState(newState State) error {
-	_, ok := fsk.states[newState]
-	if !ok {
-		return fmt.Errorf("state %w: %v", ErrUnknown, newState)
-	}
-
-	fsk.previousState = fsk.currentState
-	fsk.currentState = newState
+// Data returns the extended state of the machine.
+func (fsk *FSM[Action, State, Param]) Data() any {
+	return fsk.data
+}
 
-	return nil
+// SetData replaces the extended state of the machine.
+//
+// When called from a callback, the change is rolled back if the transition fails or is ignored.
+// Keep the data as a value, mutating it through a pointer can't be rolled back.
+func (fsk *FSM[Action, State, Param]) SetData(data any) {
+	fsk.data = data
 }
 
 func (fsk *FSM[Action, State, Param]) IgnoreCurrentTransition() {
-	if !fsk.runningApply {
+	if fsk.depth == 0 {
 		return
 	}
 
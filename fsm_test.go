@@ -908,7 +908,7 @@ func Test_force_state(t *testing.T) {
 		{
 			Name: "open", Src: []int{close}, Dst: open,
 			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
-				return instance.ForceState(roger)
+				return instance.ForceState(ctx, roger, "testing")
 			},
 		},
 		{
@@ -0,0 +1,130 @@
+package kry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// canonicalItem is what the hash of an item covers, every exported field but the hash itself.
+type canonicalItem struct {
+	Record     HistoryRecord `json:"record"`
+	StackTrace string        `json:"stack_trace,omitempty"`
+	DataBefore any           `json:"data_before,omitempty"`
+	DataAfter  any           `json:"data_after,omitempty"`
+}
+
+// canonicalEncoding returns the item encoded as JSON, which sorts the map keys.
+// It fails if the params, the data or the metadata can't be encoded as JSON.
+func canonicalEncoding[Action, State comparable, Param any](item HistoryItem[Action, State, Param]) ([]byte, error) {
+	item.Hash = ""
+
+	record, _ := NewHistoryRecord(item, nil) // no encoder, so no error
+
+	data, err := json.Marshal(canonicalItem{
+		Record:     record,
+		StackTrace: item.StackTrace,
+		DataBefore: item.DataBefore,
+		DataAfter:  item.DataAfter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode item %d: %w", item.ID, err)
+	}
+
+	return data, nil
+}
+
+// hashItem returns the hex encoded SHA-256 of the canonical encoding of the item.
+func hashItem[Action, State comparable, Param any](item HistoryItem[Action, State, Param]) (string, error) {
+	data, err := canonicalEncoding(item)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// seal hashes the items kept since the last seal, chaining them to the previous ones.
+func (hk *historyKeeper[Action, State, Param]) seal() {
+	if !hk.hashChain {
+		return
+	}
+
+	hk.locker.Lock()
+	defer hk.locker.Unlock()
+
+	// the items waiting for the sink come in the same order, the ones not kept are chained too
+	for _, item := range hk.pending {
+		hk.chain(item)
+	}
+
+	if hk.tail == nil || hk.sealed == hk.tail {
+		return
+	}
+
+	current := hk.head
+	if hk.sealed != nil {
+		current = hk.sealed.Next
+	}
+
+	for ; current != nil; current = current.Next {
+		hk.chain(current)
+		hk.sealed = current
+
+		if current == hk.tail {
+			break
+		}
+	}
+}
+
+// chain hashes the item after the last hashed one, unless it's already hashed.
+//
+// The items are checked when they are pushed, an item that can no longer be encoded
+// is left without hash, so VerifyHistory reports it.
+func (hk *historyKeeper[Action, State, Param]) chain(item *historyItem[Action, State, Param]) {
+	if item.Hash != "" {
+		return
+	}
+
+	item.PrevHash = hk.lastHash
+
+	hash, err := hashItem(*item.HistoryItem)
+	if err != nil {
+		return
+	}
+
+	item.Hash = hash
+	hk.lastHash = item.Hash
+}
+
+// VerifyHistory checks the hash chain of the items, as returned by History, and fails with
+// ErrTampered if any of them was modified, removed, inserted or reordered.
+//
+// The first item is trusted to be the start of the chain, so a history truncated by its size
+// limit is still valid. Removing items from the beginning can only be detected by comparing
+// the PrevHash of the first item with a hash known from an earlier read.
+func VerifyHistory[Action, State comparable, Param any](items []HistoryItem[Action, State, Param]) error {
+	for index, item := range items {
+		if item.Hash == "" {
+			return fmt.Errorf("item %d (id %d) isn't hashed: %w", index, item.ID, ErrTampered)
+		}
+
+		if index > 0 && item.PrevHash != items[index-1].Hash {
+			return fmt.Errorf("item %d (id %d) doesn't follow the previous one: %w", index, item.ID, ErrTampered)
+		}
+
+		hash, err := hashItem(item)
+		if err != nil {
+			return fmt.Errorf("item %d (id %d) can't be hashed: %w", index, item.ID, err)
+		}
+
+		if hash != item.Hash {
+			return fmt.Errorf("item %d (id %d) was modified: %w", index, item.ID, ErrTampered)
+		}
+	}
+
+	return nil
+}
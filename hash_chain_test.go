@@ -0,0 +1,143 @@
+package kry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_hash_chain_verify(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterVariadic: func(ctx context.Context, instance InstanceFSM[string, int, any], param ...any) error {
+				return instance.Apply(ctx, "roger", roger)
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+		{Name: "close", Src: []int{roger}, Dst: close},
+	}, WithFullHistory[any](), WithHashChain[any]())
+
+	require.NoError(t, machine.Event(context.TODO(), "open", map[string]any{"qty": 1}))
+	require.ErrorIs(t, machine.Event(context.TODO(), "open"), ErrNotFound)
+	require.Error(t, machine.Event(context.TODO(), "close", func() {})) // not encodable as JSON
+	require.Equal(t, roger, machine.Current())
+	require.NoError(t, machine.Event(context.TODO(), "close"))
+
+	history := machine.History()
+	require.Len(t, history, 4)
+	require.Empty(t, history[0].PrevHash)
+
+	for index, item := range history {
+		require.Len(t, item.Hash, 64)
+
+		if index > 0 {
+			require.Equal(t, history[index-1].Hash, item.PrevHash)
+		}
+	}
+
+	require.NoError(t, VerifyHistory(history))
+
+	modified := slices.Clone(history)
+	modified[1].To = close
+	require.ErrorIs(t, VerifyHistory(modified), ErrTampered)
+
+	modified = slices.Clone(history)
+	modified[2].Err = errors.New("other")
+	require.ErrorIs(t, VerifyHistory(modified), ErrTampered)
+
+	modified = slices.Clone(history)
+	modified[3].DataAfter = "changed"
+	require.ErrorIs(t, VerifyHistory(modified), ErrTampered)
+
+	removed := slices.Delete(slices.Clone(history), 1, 2)
+	require.ErrorIs(t, VerifyHistory(removed), ErrTampered)
+
+	reordered := slices.Clone(history)
+	reordered[1], reordered[2] = reordered[2], reordered[1]
+	require.ErrorIs(t, VerifyHistory(reordered), ErrTampered)
+
+	require.NoError(t, VerifyHistory([]HistoryItem[string, int, any]{}))
+
+	unsealed, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+	}, WithFullHistory[any]())
+	require.NoError(t, unsealed.Event(context.TODO(), "open"))
+	require.ErrorIs(t, VerifyHistory(unsealed.History()), ErrTampered)
+}
+
+func Test_hash_chain_truncated_and_rolled_back(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithHistory[any](3), WithHashChain[any]())
+
+	for range 3 {
+		require.NoError(t, machine.Event(context.TODO(), "open"))
+		require.NoError(t, machine.Event(context.TODO(), "close"))
+	}
+
+	// the first retained item anchors the chain
+	history := machine.History()
+	require.Len(t, history, 3)
+	require.NotEmpty(t, history[0].PrevHash)
+	require.NoError(t, VerifyHistory(history))
+
+	// the items are hashed once the transaction marked them as rolled back
+	err := machine.ApplySequence(context.TODO(),
+		Step[string, int, any]{Action: "open", Dst: open},
+		Step[string, int, any]{Action: "open", Dst: open},
+	)
+	require.ErrorIs(t, err, ErrRolledBack)
+
+	history = machine.History()
+	require.True(t, history[2].RolledBack)
+	require.NoError(t, VerifyHistory(history))
+}
+
+func Test_hash_chain_streamed_to_sink(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	buffer := &bytes.Buffer{}
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithHashChain[any](), WithHistorySink[any](NewJSONLinesSink[string, int, any](buffer)))
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.NoError(t, machine.Event(context.TODO(), "close"))
+
+	records := []HistoryRecord{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		record := HistoryRecord{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 2)
+	require.Len(t, records[0].Hash, 64)
+	require.Empty(t, records[0].PrevHash)
+	require.Equal(t, records[0].Hash, records[1].PrevHash)
+}
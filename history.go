@@ -1,10 +1,14 @@
 package kry
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -27,11 +31,48 @@ type HistoryItem[Action, State comparable, Param any] struct {
 	Ignored    bool
 
 	ExpectFailed bool
+	Deferred     bool // the action was deferred, or it's the replay of a deferred action
+
+	DataBefore any // extended state before the callbacks
+	DataAfter  any // extended state as the callbacks left it
+
+	RolledBack   bool   // the item belongs to a transaction that was rolled back, or it's a reverted forced state
+	Compensation bool   // the item records the outcome of a compensation
+	Attempt      int    // the attempt number when a retry policy applies, otherwise 0
+	Duplicate    bool   // a call with an already processed idempotency key, not applied again
+	Version      uint64 // version of the machine once the item was recorded, see FSM.Version
+	Unauthorized bool   // the transition was rejected by the authorizer
+	Forced       bool   // the state was forced by ForceState, Action is the zero value
+	ForceReason  string // the reason given to ForceState
+
+	Description string         // given by the transition, see Transition.Description
+	Tags        []string       // given by the transition, see Transition.Tags
+	Meta        map[string]any // given by the transition, see Transition.Meta
+
+	ID       uint64 // unique in the machine, starting from 1
+	ParentID uint64 // ID of the transition that caused this item, by its callbacks, by Raise or by unblocking a deferred action; 0 for the outermost ones
+	Depth    int    // nesting level of the transition, 0 for the outermost one
+
+	// timings are taken from the clock of the machine if enabled by WithEnabledTimings
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration // time spent in the callbacks, nested transitions included
+
+	// the hash chain enabled by WithHashChain, see VerifyHistory
+	Hash     string
+	PrevHash string
+
+	Principal    string         // identity of the caller, see ContextWithPrincipal
+	CallMetadata map[string]any // metadata given by CallOptions
 }
 
 type historyItem[Action, State comparable, Param any] struct {
 	*HistoryItem[Action, State, Param]
 	Next *historyItem[Action, State, Param]
+	Prev *historyItem[Action, State, Param]
+
+	size     int       // estimated, if the retention policy limits the bytes
+	pushedAt time.Time // if the retention policy limits the age
 }
 
 type historyKeeper[Action, State comparable, Param any] struct {
@@ -44,6 +85,26 @@ type historyKeeper[Action, State comparable, Param any] struct {
 	locker sync.Mutex
 
 	cloneHandler func(params ...Param) ([]Param, error)
+	historySettings[Action, State, Param]
+	bytes int // estimated size of the kept items
+
+	sealed   *historyItem[Action, State, Param] // the last item of the hash chain, if still kept
+	lastHash string
+
+	pending []*historyItem[Action, State, Param] // items not streamed to the sink yet, kept or not
+}
+
+// historySettings are shared by the keepers of the nested transitions
+type historySettings[Action, State comparable, Param any] struct {
+	sink          HistorySink[Action, State, Param]
+	onSinkError   func(err error)
+	redactor      func(param Param) Param
+	errorRedactor func(message string) string
+	callRedactor  func(principal string, metadata map[string]any) (string, map[string]any)
+	dataRedactor  func(data any) any
+	retention     RetentionPolicy[Param]
+	clock         Clock
+	hashChain     bool
 }
 
 func newHistoryKeeper[Action, State comparable, Param any](
@@ -102,22 +163,72 @@ func (hk *historyKeeper[Action, State, Param]) Push(
 	err error, skipStackTrace int, ignored bool, expectFailed bool,
 	params ...Param,
 ) error {
-	if hk.maxLength == 0 {
+	return hk.PushItem(
+		newHistoryItem(action, from, to, err, ignored, expectFailed, params...).HistoryItem,
+		skipStackTrace+1,
+	)
+}
+
+// PushItem keeps the given item, its params, tags and metadata are cloned before being stored.
+func (hk *historyKeeper[Action, State, Param]) PushItem(
+	newItem *HistoryItem[Action, State, Param],
+	skipStackTrace int,
+) error {
+	if hk.maxLength == 0 && hk.sink == nil {
 		return nil
 	}
 
-	cloneParams, errClone := hk.cloneHandler(params...)
+	cloneParams, errClone := hk.cloneHandler(newItem.Params...)
 	if errClone != nil {
 		return fmt.Errorf("failed to clone params: %w", errClone)
 	}
 
-	item := newHistoryItem(action, from, to, err, ignored, expectFailed, cloneParams...)
+	if hk.redactor != nil {
+		for index, param := range cloneParams {
+			cloneParams[index] = hk.redactor(param)
+		}
+	}
+
+	itemCopy := *newItem
+	itemCopy.Params = cloneParams
+	itemCopy.Tags = slices.Clone(itemCopy.Tags) // every kept item owns them, retry attempts included
+	itemCopy.Meta = maps.Clone(itemCopy.Meta)
+	itemCopy.CallMetadata = maps.Clone(itemCopy.CallMetadata)
+
+	if hk.dataRedactor != nil {
+		if itemCopy.DataBefore != nil {
+			itemCopy.DataBefore = hk.dataRedactor(itemCopy.DataBefore)
+		}
+
+		if itemCopy.DataAfter != nil {
+			itemCopy.DataAfter = hk.dataRedactor(itemCopy.DataAfter)
+		}
+	}
+
+	if hk.callRedactor != nil {
+		itemCopy.Principal, itemCopy.CallMetadata = hk.callRedactor(itemCopy.Principal, itemCopy.CallMetadata)
+	}
+
+	if hk.errorRedactor != nil && itemCopy.Err != nil {
+		itemCopy.Err = &redactedError{
+			err:     itemCopy.Err,
+			message: hk.errorRedactor(itemCopy.Err.Error()),
+		}
+	}
+	item := &historyItem[Action, State, Param]{HistoryItem: &itemCopy}
+	err := item.Err
+
+	if hk.hashChain {
+		if _, errEncoding := canonicalEncoding(itemCopy); errEncoding != nil {
+			return fmt.Errorf("hash chain: %w", errEncoding)
+		}
+	}
 
 	if hk.stackTrace && err != nil {
 		item.Reason = err.Error()
 		const depth = 64
 		pcs := make([]uintptr, depth)
-		// skip 3 frames: runtime.Callers -> push -> Push
+		// skip 3 frames: runtime.Callers -> PushItem -> Push
 		n := runtime.Callers(skipStackTrace, pcs)
 		pcs = pcs[:n]
 
@@ -134,28 +245,35 @@ func (hk *historyKeeper[Action, State, Param]) Push(
 		item.StackTrace = b.String()
 	}
 
+	if hk.maxLength == 0 {
+		hk.locker.Lock()
+		hk.pending = append(hk.pending, item)
+		hk.locker.Unlock()
+
+		return nil
+	}
+
+	hk.retain(item)
+
 	hk.locker.Lock()
 	defer hk.locker.Unlock()
 
-	if hk.length == 0 {
-		hk.head = item
-		hk.tail = item
-		hk.length++
-
-		return nil
+	if hk.sink != nil {
+		hk.pending = append(hk.pending, item)
 	}
 
-	if hk.maxLength > 0 && hk.length >= hk.maxLength {
+	if hk.tail == nil {
+		hk.head = item
+	} else {
 		hk.tail.Next = item
-		hk.tail = item
-		hk.head = hk.head.Next
-
-		return nil
+		item.Prev = hk.tail
 	}
 
-	hk.length++
-	hk.tail.Next = item
 	hk.tail = item
+	hk.length++
+	hk.bytes += item.size
+
+	hk.evict()
 
 	return nil
 }
@@ -164,6 +282,8 @@ func (hk *historyKeeper[Action, State, Param]) Items() []HistoryItem[Action, Sta
 	hk.locker.Lock()
 	defer hk.locker.Unlock()
 
+	hk.evict() // the items may have expired since the last push
+
 	items := make([]HistoryItem[Action, State, Param], 0, hk.length)
 
 	current := hk.head
@@ -176,67 +296,145 @@ func (hk *historyKeeper[Action, State, Param]) Items() []HistoryItem[Action, Sta
 }
 
 func (hk *historyKeeper[Action, State, Param]) Append(other *historyKeeper[Action, State, Param]) {
-	if other.length == 0 {
+	if other.length == 0 && len(other.pending) == 0 {
 		return
 	}
 
 	hk.locker.Lock()
 	defer hk.locker.Unlock()
 
-	if hk.tail == nil {
-		hk.head = other.head
-		hk.tail = other.tail
-		hk.length = other.length
+	hk.pending = append(hk.pending, other.pending...)
 
+	if other.length == 0 {
 		return
 	}
 
-	hk.tail.Next = other.head
+	if hk.tail == nil {
+		hk.head = other.head
+	} else {
+		hk.tail.Next = other.head
+		other.head.Prev = hk.tail
+	}
+
 	hk.tail = other.tail
 	hk.length += other.length
+	hk.bytes += other.bytes
+
+	hk.evict()
+}
 
-	if hk.maxLength > 0 && hk.length > hk.maxLength {
-		excess := hk.length - hk.maxLength
-		current := hk.head
+// MarkRolledBack flags every item kept after the given one as rolled back, or every item if after
+// is nil, along with the items waiting for the sink from the given index.
+func (hk *historyKeeper[Action, State, Param]) MarkRolledBack(after *historyItem[Action, State, Param], pendingFrom int) {
+	hk.locker.Lock()
+	defer hk.locker.Unlock()
+
+	for _, item := range hk.pending[min(pendingFrom, len(hk.pending)):] {
+		item.RolledBack = true
+	}
 
-		for range excess {
-			current = current.Next
+	current := hk.head
+	if after != nil {
+		current = after.Next
+	}
+
+	for current != nil {
+		current.RolledBack = true
+		current = current.Next
+	}
+}
+
+// MarkForcedReverted flags the forced states kept as rolled back,
+// for the keeper of a transition that reverted them.
+func (hk *historyKeeper[Action, State, Param]) MarkForcedReverted() {
+	hk.locker.Lock()
+	defer hk.locker.Unlock()
+
+	mark := func(item *historyItem[Action, State, Param]) {
+		if item.Forced && item.Err == nil {
+			item.RolledBack = true
 		}
+	}
 
-		hk.head = current
-		hk.length = hk.maxLength
+	for _, item := range hk.pending {
+		mark(item)
+	}
+
+	for current := hk.head; current != nil; current = current.Next {
+		mark(current)
+
+		if current == hk.tail {
+			break
+		}
 	}
 }
 
 // the following methods are added to FSM because they relate to history management
 
-func (fsk *FSM[Action, State, Param]) intermediateKeeper(
-	historyKeeper *historyKeeper[Action, State, Param],
+// newItem returns a history item filled with the data carried by ctx.
+func (fsk *FSM[Action, State, Param]) newItem(
+	ctx context.Context,
+	action Action,
+	from, to State,
+	param ...Param,
+) *HistoryItem[Action, State, Param] {
+	item := newHistoryItem(action, from, to, nil, false, false, param...).HistoryItem
+	item.Principal = PrincipalFromContext(ctx)
+	item.Version = fsk.version
+	item.Depth = fsk.depth
+	item.ID = fsk.nextItemID()
+	item.ParentID = fsk.parentItemID
+
+	if fsk.timings {
+		item.Start = fsk.clock.Now()
+		item.End = item.Start
+	}
+
+	if scope := callScopeFromContext[Action, State, Param](ctx); scope != nil {
+		item.CallMetadata = scope.metadata
+	}
+
+	return item
+}
+
+// keepFailure keeps the item of a transition that failed before its callbacks were called.
+func (fsk *FSM[Action, State, Param]) keepFailure(
+	ctx context.Context,
 	action Action,
 	from, to State,
 	err error,
-	ignored bool,
-	expectFailed bool,
 	param ...Param,
-) (*historyKeeper[Action, State, Param], error) {
-	finalKeeper := newHistoryKeeper[Action, State](
+) error {
+	item := fsk.newItem(ctx, action, from, to, param...)
+	item.Err = err
+	item.Ignored = fsk.ignoreCurrent
+
+	return fsk.historyKeeper.PushItem(item, defaultSkipStackTrace+1)
+}
+
+func (fsk *FSM[Action, State, Param]) newHistoryKeeper() *historyKeeper[Action, State, Param] {
+	historyKeeper := newHistoryKeeper[Action, State](
 		fsk.historyKeeper.maxLength,
 		fsk.stackTrace,
 		fsk.cloneHandler,
 	)
+	historyKeeper.historySettings = fsk.historyKeeper.historySettings
 
-	errHistory := finalKeeper.Push(
-		action, from, to,
-		err, defaultSkipStackTrace, ignored, expectFailed,
-		param...,
-	)
+	return historyKeeper
+}
+
+func (fsk *FSM[Action, State, Param]) intermediateKeeper(
+	historyKeeper *historyKeeper[Action, State, Param],
+	item *HistoryItem[Action, State, Param],
+) (*historyKeeper[Action, State, Param], error) {
+	finalKeeper := fsk.newHistoryKeeper()
+
+	errHistory := finalKeeper.PushItem(item, defaultSkipStackTrace)
 	if errHistory != nil {
 		return nil, fmt.Errorf("failed to push history item: %w", errHistory)
 	}
 
-	if historyKeeper.length > 0 {
-		finalKeeper.Append(historyKeeper)
-	}
+	finalKeeper.Append(historyKeeper)
 
 	return finalKeeper, nil
 }
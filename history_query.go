@@ -0,0 +1,155 @@
+package kry
+
+import (
+	"iter"
+	"slices"
+	"time"
+)
+
+// HistoryQuery reads the history keeping only the items that match all of its filters,
+// the matching items are copied at once when the iteration starts, so later changes don't affect it.
+// Filters return a new query, so a query can be reused.
+type HistoryQuery[Action, State comparable, Param any] struct {
+	fsk     *FSM[Action, State, Param]
+	filters []func(item *HistoryItem[Action, State, Param]) bool
+}
+
+// HistoryQuery returns a query over the whole history.
+func (fsk *FSM[Action, State, Param]) HistoryQuery() HistoryQuery[Action, State, Param] {
+	return HistoryQuery[Action, State, Param]{fsk: fsk}
+}
+
+func (q HistoryQuery[Action, State, Param]) where(
+	filter func(item *HistoryItem[Action, State, Param]) bool,
+) HistoryQuery[Action, State, Param] {
+	q.filters = append(slices.Clip(q.filters), filter)
+
+	return q
+}
+
+// Action keeps the items of the given actions.
+func (q HistoryQuery[Action, State, Param]) Action(actions ...Action) HistoryQuery[Action, State, Param] {
+	return q.where(func(item *HistoryItem[Action, State, Param]) bool {
+		return slices.Contains(actions, item.Action)
+	})
+}
+
+// State keeps the items that leave or enter any of the given states.
+func (q HistoryQuery[Action, State, Param]) State(states ...State) HistoryQuery[Action, State, Param] {
+	return q.where(func(item *HistoryItem[Action, State, Param]) bool {
+		return slices.Contains(states, item.From) || slices.Contains(states, item.To)
+	})
+}
+
+// Errors keeps the failed items.
+func (q HistoryQuery[Action, State, Param]) Errors() HistoryQuery[Action, State, Param] {
+	return q.where(func(item *HistoryItem[Action, State, Param]) bool {
+		return item.Err != nil
+	})
+}
+
+// Ignored keeps the items of the ignored transitions.
+func (q HistoryQuery[Action, State, Param]) Ignored() HistoryQuery[Action, State, Param] {
+	return q.where(func(item *HistoryItem[Action, State, Param]) bool {
+		return item.Ignored
+	})
+}
+
+// ExpectFailed keeps the items whose expectations were not met.
+func (q HistoryQuery[Action, State, Param]) ExpectFailed() HistoryQuery[Action, State, Param] {
+	return q.where(func(item *HistoryItem[Action, State, Param]) bool {
+		return item.ExpectFailed
+	})
+}
+
+// Between keeps the items started in [from, to), it needs WithEnabledTimings.
+func (q HistoryQuery[Action, State, Param]) Between(from, to time.Time) HistoryQuery[Action, State, Param] {
+	return q.where(func(item *HistoryItem[Action, State, Param]) bool {
+		return !item.Start.Before(from) && item.Start.Before(to)
+	})
+}
+
+func (q HistoryQuery[Action, State, Param]) match(item *HistoryItem[Action, State, Param]) bool {
+	for _, filter := range q.filters {
+		if !filter(item) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// collect copies, under the lock, the matching items kept after the item with the given ID,
+// only the newest limit ones if limit > 0. The items are returned from the oldest to the newest.
+//
+// The IDs grow in the order of the history, so only the items after the given ID are visited.
+func (q HistoryQuery[Action, State, Param]) collect(since uint64, limit int) []HistoryItem[Action, State, Param] {
+	keeper := q.fsk.historyKeeper
+
+	keeper.locker.Lock()
+	defer keeper.locker.Unlock()
+
+	keeper.evict()
+
+	items := []HistoryItem[Action, State, Param]{}
+
+	for current := keeper.tail; current != nil && current.ID > since; current = current.Prev {
+		if limit > 0 && len(items) >= limit {
+			break
+		}
+
+		if q.match(current.HistoryItem) {
+			items = append(items, *current.HistoryItem)
+		}
+	}
+
+	slices.Reverse(items)
+
+	return items
+}
+
+// All iterates over the matching items, from the oldest to the newest.
+func (q HistoryQuery[Action, State, Param]) All() iter.Seq[HistoryItem[Action, State, Param]] {
+	return func(yield func(HistoryItem[Action, State, Param]) bool) {
+		for _, item := range q.collect(0, 0) {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Backward iterates over the matching items, from the newest to the oldest.
+func (q HistoryQuery[Action, State, Param]) Backward() iter.Seq[HistoryItem[Action, State, Param]] {
+	return func(yield func(HistoryItem[Action, State, Param]) bool) {
+		for _, item := range slices.Backward(q.collect(0, 0)) {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Last returns the newest n matching items, from the oldest to the newest.
+// Only the items up to the n-th newest match are visited.
+func (q HistoryQuery[Action, State, Param]) Last(n int) []HistoryItem[Action, State, Param] {
+	if n <= 0 {
+		return []HistoryItem[Action, State, Param]{}
+	}
+
+	return q.collect(0, n)
+}
+
+// Since iterates over the matching items kept after the item with the given ID,
+// so a consumer only reads what's new since its last poll. Since(0) is the same as All.
+//
+// Only the items after the given ID are visited, so a poll costs what's new, not the whole history.
+func (q HistoryQuery[Action, State, Param]) Since(id uint64) iter.Seq[HistoryItem[Action, State, Param]] {
+	return func(yield func(HistoryItem[Action, State, Param]) bool) {
+		for _, item := range q.collect(id, 0) {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
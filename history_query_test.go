@@ -0,0 +1,143 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_history_query(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	errExpected := errors.New("expected")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+		{
+			Name: "roger", Src: []int{open}, Dst: roger,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return errExpected
+			},
+		},
+		{
+			Name: "skip", Src: []int{close}, Dst: roger,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				instance.IgnoreCurrentTransition()
+
+				return nil
+			},
+		},
+	}, WithFullHistory[any](), WithEnabledTimings[any](), WithClock[any](clock))
+
+	for _, action := range []string{"open", "roger", "close", "skip", "close"} {
+		_ = machine.Event(context.TODO(), action)
+		clock.now = clock.now.Add(time.Minute)
+	}
+
+	actions := func(items []HistoryItem[string, int, any]) []string {
+		result := []string{}
+		for _, item := range items {
+			result = append(result, item.Action)
+		}
+
+		return result
+	}
+
+	query := machine.HistoryQuery()
+	require.Equal(t, machine.History(), slices.Collect(query.All()))
+	require.Equal(t, []string{"close", "skip", "close", "roger", "open"}, actions(slices.Collect(query.Backward())))
+
+	require.Equal(t, []string{"close", "close"}, actions(slices.Collect(query.Action("close").All())))
+	require.Equal(t, []string{"open", "roger"}, actions(slices.Collect(query.State(open).Action("open", "roger").All())))
+	require.Equal(t, []string{"roger", "close"}, actions(slices.Collect(query.Errors().All())))
+	require.Equal(t, []string{"skip"}, actions(slices.Collect(query.Ignored().All())))
+	require.Empty(t, slices.Collect(query.ExpectFailed().All()))
+	require.Equal(t, []string{"roger", "close"},
+		actions(slices.Collect(query.Between(start.Add(time.Minute), start.Add(3*time.Minute)).All())))
+
+	require.Equal(t, []string{"skip", "close"}, actions(query.Last(2)))
+	require.Equal(t, []string{"open", "roger", "close", "skip", "close"}, actions(query.Last(10)))
+	require.Empty(t, query.Last(0))
+
+	// an incremental consumer keeps the last ID it read
+	last := query.Last(1)[0].ID
+	require.Empty(t, slices.Collect(query.Since(last)))
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.Equal(t, []string{"open"}, actions(slices.Collect(query.Since(last))))
+	require.Len(t, slices.Collect(query.Since(0)), 6)
+}
+
+func Test_history_query_limited(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithHistory[any](2))
+
+	for range 3 {
+		require.NoError(t, machine.Event(context.TODO(), "open"))
+		require.NoError(t, machine.Event(context.TODO(), "close"))
+	}
+
+	ids := []uint64{}
+	for item := range machine.HistoryQuery().Since(1) {
+		ids = append(ids, item.ID)
+	}
+
+	require.Equal(t, []uint64{5, 6}, ids)
+
+	// stopping early
+	for item := range machine.HistoryQuery().Backward() {
+		require.Equal(t, uint64(6), item.ID)
+
+		break
+	}
+}
+
+func Test_history_query_is_a_snapshot(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithFullHistory[any]())
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.NoError(t, machine.Event(context.TODO(), "close"))
+
+	visited := 0
+	for item := range machine.HistoryQuery().All() {
+		visited++
+
+		// the items recorded or rolled back while iterating are not seen
+		require.ErrorIs(t, machine.ApplySequence(context.TODO(),
+			Step[string, int, any]{Action: "open", Dst: open},
+			Step[string, int, any]{Action: "open", Dst: open},
+		), ErrRolledBack)
+		require.False(t, item.RolledBack)
+	}
+
+	require.Equal(t, 2, visited)
+	require.Len(t, machine.History(), 6)
+	require.Len(t, machine.HistoryQuery().Last(3), 3)
+	require.Equal(t, uint64(6), machine.HistoryQuery().Last(1)[0].ID)
+}
@@ -0,0 +1,326 @@
+package kry
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistorySink receives the history items, see WithHistorySink.
+//
+// Items are streamed once the outermost call that recorded them settles, in the order of History,
+// so they carry the flags set afterwards, as RolledBack, and the hashes of WithHashChain.
+type HistorySink[Action, State comparable, Param any] = func(item HistoryItem[Action, State, Param]) error
+
+// ParamEncoder turns the params of an item into something the encoders can serialize.
+type ParamEncoder[Param any] = func(params []Param) (any, error)
+
+type SinkOptions[Param any] struct {
+	paramEncoder ParamEncoder[Param]
+}
+
+// WithParamEncoder sets how the params are encoded by the sinks, they're kept as they are by default.
+func WithParamEncoder[Param any](encoder ParamEncoder[Param]) func(o *SinkOptions[Param]) *SinkOptions[Param] {
+	return func(o *SinkOptions[Param]) *SinkOptions[Param] {
+		o.paramEncoder = encoder
+
+		return o
+	}
+}
+
+// errorKinds are ordered so the sentinels that wrap other errors come first
+var errorKinds = []error{
+	ErrRolledBack, ErrEffects, ErrRaised, ErrTimeout, ErrDone, ErrVersionMismatch, ErrStopped, ErrExpired,
+	ErrLoopFound, ErrNotAllowed, ErrUnknown, ErrNotFound, ErrRepeated,
+	context.Canceled, context.DeadlineExceeded,
+}
+
+// ErrorKind classifies err by the sentinel error it wraps, as ErrRolledBack or ErrNotFound.
+// It returns "" for a nil error and "other" for errors that wrap no sentinel.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return kind.Error()
+		}
+	}
+
+	return "other"
+}
+
+// HistoryRecord is the serializable form of a history item.
+type HistoryRecord struct {
+	ID       uint64 `json:"id"`
+	ParentID uint64 `json:"parent_id,omitempty"`
+	Depth    int    `json:"depth"`
+	Version  uint64 `json:"version"`
+
+	Action any `json:"action"`
+	From   any `json:"from"`
+	To     any `json:"to"`
+	Params any `json:"params,omitempty"`
+
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+
+	Ignored      bool `json:"ignored,omitempty"`
+	ExpectFailed bool `json:"expect_failed,omitempty"`
+	Deferred     bool `json:"deferred,omitempty"`
+	RolledBack   bool `json:"rolled_back,omitempty"`
+	Compensation bool `json:"compensation,omitempty"`
+	Duplicate    bool `json:"duplicate,omitempty"`
+	Unauthorized bool `json:"unauthorized,omitempty"`
+	Forced       bool `json:"forced,omitempty"`
+	Attempt      int  `json:"attempt,omitempty"`
+
+	ForceReason  string         `json:"force_reason,omitempty"`
+	Principal    string         `json:"principal,omitempty"`
+	CallMetadata map[string]any `json:"call_metadata,omitempty"`
+
+	Description string         `json:"description,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Meta        map[string]any `json:"meta,omitempty"`
+
+	Start    *time.Time    `json:"start,omitempty"`
+	End      *time.Time    `json:"end,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+
+	Hash     string `json:"hash,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
+}
+
+// NewHistoryRecord returns the serializable form of the item, params are encoded by paramEncoder if given.
+func NewHistoryRecord[Action, State comparable, Param any](
+	item HistoryItem[Action, State, Param],
+	paramEncoder ParamEncoder[Param],
+) (HistoryRecord, error) {
+	record := HistoryRecord{
+		ID:           item.ID,
+		ParentID:     item.ParentID,
+		Depth:        item.Depth,
+		Version:      item.Version,
+		Action:       item.Action,
+		From:         item.From,
+		To:           item.To,
+		ErrorKind:    ErrorKind(item.Err),
+		Reason:       item.Reason,
+		Ignored:      item.Ignored,
+		ExpectFailed: item.ExpectFailed,
+		Deferred:     item.Deferred,
+		RolledBack:   item.RolledBack,
+		Compensation: item.Compensation,
+		Duplicate:    item.Duplicate,
+		Unauthorized: item.Unauthorized,
+		Forced:       item.Forced,
+		ForceReason:  item.ForceReason,
+		Attempt:      item.Attempt,
+		Principal:    item.Principal,
+		CallMetadata: item.CallMetadata,
+		Description:  item.Description,
+		Tags:         item.Tags,
+		Meta:         item.Meta,
+		Duration:     item.Duration,
+		Hash:         item.Hash,
+		PrevHash:     item.PrevHash,
+	}
+
+	if item.Err != nil {
+		record.Error = item.Err.Error()
+	}
+
+	if !item.Start.IsZero() {
+		record.Start = &item.Start
+		record.End = &item.End
+	}
+
+	if len(item.Params) > 0 {
+		record.Params = item.Params
+
+		if paramEncoder != nil {
+			params, err := paramEncoder(item.Params)
+			if err != nil {
+				return HistoryRecord{}, fmt.Errorf("failed to encode params: %w", err)
+			}
+
+			record.Params = params
+		}
+	}
+
+	return record, nil
+}
+
+func newSinkOptions[Param any](options []func(o *SinkOptions[Param]) *SinkOptions[Param]) *SinkOptions[Param] {
+	finalOptions := &SinkOptions[Param]{}
+	for _, opt := range options {
+		finalOptions = opt(finalOptions)
+	}
+
+	return finalOptions
+}
+
+// flush streams the pending items to the sink, in order. It stops at the first item the sink fails
+// to take, which is kept with the following ones for the next flush.
+func (hk *historyKeeper[Action, State, Param]) flush() error {
+	if hk.sink == nil {
+		return nil
+	}
+
+	hk.locker.Lock()
+	pending := hk.pending
+	hk.pending = nil
+	hk.locker.Unlock()
+
+	for index, item := range pending {
+		if err := hk.sink(*item.HistoryItem); err != nil {
+			hk.locker.Lock()
+			hk.pending = slices.Concat(pending[index:], hk.pending)
+			hk.locker.Unlock()
+
+			return fmt.Errorf("history sink, item %d: %w", item.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// settleHistory seals and streams the history once the outermost call finishes,
+// the errors of the sink go to the handler given by WithHistorySinkErrors.
+func (fsk *FSM[Action, State, Param]) settleHistory() {
+	if fsk.depth > 0 || fsk.draining || fsk.tx != nil {
+		return
+	}
+
+	fsk.historyKeeper.seal()
+
+	if err := fsk.historyKeeper.flush(); err != nil && fsk.historyKeeper.onSinkError != nil {
+		fsk.historyKeeper.onSinkError(err)
+	}
+}
+
+// FlushHistory streams again the items the sink failed to take. It's not allowed while a transition is running.
+func (fsk *FSM[Action, State, Param]) FlushHistory() error {
+	if fsk.depth > 0 || fsk.draining || fsk.tx != nil {
+		return fmt.Errorf("flush history during a transition: %w", ErrNotAllowed)
+	}
+
+	fsk.historyKeeper.seal()
+
+	return fsk.historyKeeper.flush()
+}
+
+// NewJSONLinesSink writes every item as a HistoryRecord in its own JSON line.
+func NewJSONLinesSink[Action, State comparable, Param any](
+	writer io.Writer,
+	options ...func(o *SinkOptions[Param]) *SinkOptions[Param],
+) HistorySink[Action, State, Param] {
+	finalOptions := newSinkOptions(options)
+	encoder := json.NewEncoder(writer)
+
+	return func(item HistoryItem[Action, State, Param]) error {
+		record, err := NewHistoryRecord(item, finalOptions.paramEncoder)
+		if err != nil {
+			return err
+		}
+
+		return encoder.Encode(record)
+	}
+}
+
+var csvHeader = []string{
+	"id", "parent_id", "depth", "version", "action", "from", "to", "params",
+	"error", "error_kind", "ignored", "expect_failed", "deferred", "rolled_back",
+	"compensation", "duplicate", "unauthorized", "forced", "force_reason", "attempt", "principal",
+	"description", "tags", "start", "end", "duration_ns", "hash", "prev_hash",
+}
+
+// NewCSVSink writes every item as a CSV row, the header is written before the first row.
+// Params are written as JSON, and the timestamps in RFC 3339 with nanoseconds.
+func NewCSVSink[Action, State comparable, Param any](
+	writer io.Writer,
+	options ...func(o *SinkOptions[Param]) *SinkOptions[Param],
+) HistorySink[Action, State, Param] {
+	finalOptions := newSinkOptions(options)
+	csvWriter := csv.NewWriter(writer)
+	headerWritten := false
+
+	return func(item HistoryItem[Action, State, Param]) error {
+		record, err := NewHistoryRecord(item, finalOptions.paramEncoder)
+		if err != nil {
+			return err
+		}
+
+		params := ""
+		if record.Params != nil {
+			encoded, err := json.Marshal(record.Params)
+			if err != nil {
+				return fmt.Errorf("failed to encode params: %w", err)
+			}
+
+			params = string(encoded)
+		}
+
+		timestamp := func(value *time.Time) string {
+			if value == nil {
+				return ""
+			}
+
+			return value.Format(time.RFC3339Nano)
+		}
+
+		if !headerWritten {
+			if err := csvWriter.Write(csvHeader); err != nil {
+				return err
+			}
+
+			headerWritten = true
+		}
+
+		if err := csvWriter.Write([]string{
+			strconv.FormatUint(record.ID, 10),
+			strconv.FormatUint(record.ParentID, 10),
+			strconv.Itoa(record.Depth),
+			strconv.FormatUint(record.Version, 10),
+			fmt.Sprint(record.Action),
+			fmt.Sprint(record.From),
+			fmt.Sprint(record.To),
+			params,
+			record.Error,
+			record.ErrorKind,
+			strconv.FormatBool(record.Ignored),
+			strconv.FormatBool(record.ExpectFailed),
+			strconv.FormatBool(record.Deferred),
+			strconv.FormatBool(record.RolledBack),
+			strconv.FormatBool(record.Compensation),
+			strconv.FormatBool(record.Duplicate),
+			strconv.FormatBool(record.Unauthorized),
+			strconv.FormatBool(record.Forced),
+			record.ForceReason,
+			strconv.Itoa(record.Attempt),
+			record.Principal,
+			record.Description,
+			strings.Join(record.Tags, ";"),
+			timestamp(record.Start),
+			timestamp(record.End),
+			strconv.FormatInt(int64(record.Duration), 10),
+			record.Hash,
+			record.PrevHash,
+		}); err != nil {
+			return err
+		}
+
+		csvWriter.Flush()
+
+		return csvWriter.Error()
+	}
+}
@@ -0,0 +1,186 @@
+package kry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_history_sink_receives_items_without_history(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	items := []HistoryItem[string, int, any]{}
+	sink := func(item HistoryItem[string, int, any]) error {
+		items = append(items, item)
+
+		return nil
+	}
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+	}, WithHistorySink[any](sink))
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "open", 42))
+	require.ErrorIs(t, machine.Event(context.TODO(), "open"), ErrNotFound)
+
+	require.Empty(t, machine.History())
+	require.Equal(t, []HistoryItem[string, int, any]{
+		{Action: "open", From: close, To: open, Params: []any{42}, Version: 1, ID: 1},
+		{Action: "open", From: open, To: open, Err: ErrNotFound, Version: 1, ID: 2},
+	}, items)
+
+	// a failing sink doesn't fail the transition, the items are streamed again later
+	errSink := errors.New("sink")
+	sinkErrs := []error{}
+	streamed := []uint64{}
+
+	failing, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithHistorySink[any](func(item HistoryItem[string, int, any]) error {
+		if len(sinkErrs) == 0 {
+			return errSink
+		}
+
+		streamed = append(streamed, item.ID)
+
+		return nil
+	}), WithHistorySinkErrors[any](func(err error) {
+		sinkErrs = append(sinkErrs, err)
+	}))
+
+	require.NoError(t, failing.Event(context.TODO(), "open"))
+	require.Equal(t, open, failing.Current())
+	require.Len(t, sinkErrs, 1)
+	require.ErrorIs(t, sinkErrs[0], errSink)
+	require.Empty(t, streamed)
+
+	require.NoError(t, failing.Event(context.TODO(), "close"))
+	require.Equal(t, []uint64{1, 2}, streamed)
+	require.NoError(t, failing.FlushHistory())
+
+	_, err = New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+	}, WithHistorySink[any](func(item HistoryItem[string, string, any]) error {
+		return nil
+	}))
+	require.ErrorIs(t, err, ErrNotAllowed)
+}
+
+func Test_history_sink_json_lines(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	buffer := &bytes.Buffer{}
+	sink := NewJSONLinesSink[string, int](buffer, WithParamEncoder(func(params []string) (any, error) {
+		return strings.Join(params, ","), nil
+	}))
+
+	machine, _ := New(close, []Transition[string, int, string]{
+		{Name: "open", Src: []int{close}, Dst: open, Tags: []string{"audit"}},
+	}, WithHistorySink[string](sink))
+
+	require.NoError(t, machine.Event(ContextWithPrincipal(context.TODO(), "alice"), "open", "a", "b"))
+	require.Error(t, machine.Event(context.TODO(), "open"))
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	require.Len(t, lines, 2)
+
+	require.JSONEq(t, `{"id":1,"depth":0,"version":1,"action":"open","from":1,"to":2,
+		"params":"a,b","principal":"alice","tags":["audit"]}`, lines[0])
+
+	record := HistoryRecord{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	require.Equal(t, "not found", record.Error)
+	require.Equal(t, "not found", record.ErrorKind)
+}
+
+func Test_history_sink_csv(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	errExpected := errors.New("expected")
+	buffer := &bytes.Buffer{}
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Enter: func(ctx context.Context, instance InstanceFSM[string, int, any], param any) error {
+				return fmt.Errorf("wrapped: %w", errExpected)
+			},
+		},
+	}, WithHistorySink[any](NewCSVSink[string, int, any](buffer)))
+
+	require.ErrorIs(t, machine.Event(context.TODO(), "open", map[string]int{"qty": 1}), errExpected)
+
+	expected := "id,parent_id,depth,version,action,from,to,params,error,error_kind,ignored,expect_failed," +
+		"deferred,rolled_back,compensation,duplicate,unauthorized,forced,force_reason,attempt,principal,description,tags," +
+		"start,end,duration_ns,hash,prev_hash\n" +
+		`1,0,0,0,open,1,2,"[{""qty"":1}]",wrapped: expected,other,false,false,false,false,false,false,false,false,,0,,,,,,0,,` + "\n"
+	require.Equal(t, expected, buffer.String())
+}
+
+func Test_error_kind(t *testing.T) {
+	require.Equal(t, "", ErrorKind(nil))
+	require.Equal(t, "other", ErrorKind(errors.New("custom")))
+	require.Equal(t, "rolled back", ErrorKind(fmt.Errorf("%w: %w", ErrRolledBack, ErrNotFound)))
+	require.Equal(t, "context canceled", ErrorKind(fmt.Errorf("refused: %w", context.Canceled)))
+}
+
+func Test_history_sink_streams_settled_items_in_order(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+		locked
+	)
+
+	items := []HistoryItem[string, int, any]{}
+	sink := func(item HistoryItem[string, int, any]) error {
+		items = append(items, item)
+
+		return nil
+	}
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				if err := instance.Apply(ctx, "roger", roger); err != nil {
+					return err
+				}
+
+				_ = instance.Apply(ctx, "lock", locked) // the error is swallowed on purpose
+
+				return nil
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+		{Name: "lock", Src: []int{open}, Dst: locked},
+	}, WithFullHistory[any](), WithHistorySink[any](sink))
+	require.NoError(t, err)
+
+	require.ErrorIs(t, machine.ApplyTx(context.TODO(), "open", open), ErrRolledBack)
+
+	// the same as the history, parents first and flagged as rolled back
+	require.Equal(t, machine.History(), items)
+	require.Equal(t, []string{"open", "roger", "lock"}, []string{items[0].Action, items[1].Action, items[2].Action})
+
+	for _, item := range items {
+		require.True(t, item.RolledBack)
+	}
+}
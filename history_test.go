@@ -176,18 +176,22 @@ func Test_history_in_machine(t *testing.T) {
 
 	expectedHistory := []HistoryItem[string, int, any]{
 		{
-			Action: "open",
-			From:   close,
-			To:     open,
-			Params: nil,
-			Err:    nil,
-		},
-		{
-			Action: "close",
-			From:   open,
-			To:     close,
-			Params: nil,
-			Err:    nil,
+			Action:  "open",
+			From:    close,
+			To:      open,
+			Params:  nil,
+			Err:     nil,
+			Version: 1,
+			ID:      1,
+		},
+		{
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Params:  nil,
+			Err:     nil,
+			Version: 2,
+			ID:      2,
 		},
 	}
 	require.Equal(t, expectedHistory, machine.History())
@@ -212,11 +216,13 @@ func Test_history_in_machine_limited(t *testing.T) {
 
 	expectedHistory := []HistoryItem[string, int, any]{
 		{
-			Action: "close",
-			From:   open,
-			To:     close,
-			Params: nil,
-			Err:    nil,
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Params:  nil,
+			Err:     nil,
+			Version: 2,
+			ID:      2,
 		},
 	}
 	require.Equal(t, expectedHistory, machine.History())
@@ -268,32 +274,40 @@ func Test_history_in_machine_with_error_from_enter(t *testing.T) {
 
 	expectedHistory := []HistoryItem[string, int, string]{
 		{
-			Action: "roger",
-			From:   close,
-			To:     roger,
-			Params: nil,
-			Err:    nil,
-		},
-		{
-			Action: "open",
-			From:   roger,
-			To:     open,
-			Params: []string{"fail"},
-			Err:    ErrNotAllowed,
-		},
-		{
-			Action: "open",
-			From:   roger,
-			To:     open,
-			Params: nil,
-			Err:    nil,
-		},
-		{
-			Action: "close",
-			From:   open,
-			To:     close,
-			Params: nil,
-			Err:    nil,
+			Action:  "roger",
+			From:    close,
+			To:      roger,
+			Params:  nil,
+			Err:     nil,
+			Version: 1,
+			ID:      1,
+		},
+		{
+			Action:  "open",
+			From:    roger,
+			To:      open,
+			Params:  []string{"fail"},
+			Err:     ErrNotAllowed,
+			Version: 1,
+			ID:      2,
+		},
+		{
+			Action:  "open",
+			From:    roger,
+			To:      open,
+			Params:  nil,
+			Err:     nil,
+			Version: 2,
+			ID:      3,
+		},
+		{
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Params:  nil,
+			Err:     nil,
+			Version: 3,
+			ID:      4,
 		},
 	}
 	require.Equal(t, expectedHistory, machine.History())
@@ -338,32 +352,40 @@ func Test_history_in_machine_with_incorrect_transition_error(t *testing.T) {
 
 	expectedHistory := []HistoryItem[string, int, string]{
 		{
-			Action: "roger",
-			From:   close,
-			To:     roger,
-			Params: nil,
-			Err:    nil,
-		},
-		{
-			Action: "open",
-			From:   roger,
-			To:     open,
-			Params: nil,
-			Err:    nil,
-		},
-		{
-			Action: "roger",
-			From:   open,
-			To:     roger,
-			Params: nil,
-			Err:    ErrNotFound,
-		},
-		{
-			Action: "close",
-			From:   open,
-			To:     close,
-			Params: nil,
-			Err:    nil,
+			Action:  "roger",
+			From:    close,
+			To:      roger,
+			Params:  nil,
+			Err:     nil,
+			Version: 1,
+			ID:      1,
+		},
+		{
+			Action:  "open",
+			From:    roger,
+			To:      open,
+			Params:  nil,
+			Err:     nil,
+			Version: 2,
+			ID:      2,
+		},
+		{
+			Action:  "roger",
+			From:    open,
+			To:      roger,
+			Params:  nil,
+			Err:     ErrNotFound,
+			Version: 2,
+			ID:      3,
+		},
+		{
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Params:  nil,
+			Err:     nil,
+			Version: 3,
+			ID:      4,
 		},
 	}
 	require.Equal(t, expectedHistory, machine.History())
@@ -500,53 +522,75 @@ func Test_history_in_machine_apply_within_apply_case1(t *testing.T) {
 
 	expectedHistory := []HistoryItem[string, int, string]{
 		{
-			Action: "roger",
-			From:   close,
-			To:     roger1,
-			Params: []string{emptyString},
-			Err:    nil,
-		},
-		{
-			Action: "roger",
-			From:   roger1,
-			To:     roger2,
-			Params: []string{emptyString},
-			Err:    nil,
-		},
-		{
-			Action: "roger",
-			From:   roger2,
-			To:     roger3,
-			Params: []string{emptyString},
-			Err:    nil,
-		},
-		{
-			Action: "roger",
-			From:   roger3,
-			To:     roger4,
-			Params: []string{emptyString},
-			Err:    nil,
-		},
-		{
-			Action: "roger",
-			From:   roger4,
-			To:     roger5,
-			Params: []string{emptyString},
-			Err:    nil,
-		},
-		{
-			Action: "open",
-			From:   roger5,
-			To:     open,
-			Params: []string{emptyString},
-			Err:    nil,
-		},
-		{
-			Action: "close",
-			From:   open,
-			To:     close,
-			Params: []string{emptyString},
-			Err:    nil,
+			Action:  "roger",
+			From:    close,
+			To:      roger1,
+			Params:  []string{emptyString},
+			Err:     nil,
+			Version: 5,
+			ID:      1,
+		},
+		{
+			Action:   "roger",
+			From:     roger1,
+			To:       roger2,
+			Params:   []string{emptyString},
+			Err:      nil,
+			Version:  4,
+			Depth:    1,
+			ID:       2,
+			ParentID: 1,
+		},
+		{
+			Action:   "roger",
+			From:     roger2,
+			To:       roger3,
+			Params:   []string{emptyString},
+			Err:      nil,
+			Version:  3,
+			Depth:    2,
+			ID:       3,
+			ParentID: 2,
+		},
+		{
+			Action:   "roger",
+			From:     roger3,
+			To:       roger4,
+			Params:   []string{emptyString},
+			Err:      nil,
+			Version:  2,
+			Depth:    3,
+			ID:       4,
+			ParentID: 3,
+		},
+		{
+			Action:   "roger",
+			From:     roger4,
+			To:       roger5,
+			Params:   []string{emptyString},
+			Err:      nil,
+			Version:  1,
+			Depth:    4,
+			ID:       5,
+			ParentID: 4,
+		},
+		{
+			Action:  "open",
+			From:    roger5,
+			To:      open,
+			Params:  []string{emptyString},
+			Err:     nil,
+			Version: 6,
+			ID:      6,
+		},
+		{
+			Action:  "close",
+			From:    open,
+			To:      close,
+			Params:  []string{emptyString},
+			Err:     nil,
+			Version: 7,
+			ID:      7,
 		},
 	}
 	require.Equal(t, expectedHistory, machine.History())
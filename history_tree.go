@@ -0,0 +1,118 @@
+package kry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HistoryNode is a history item along with the items caused by its callbacks.
+type HistoryNode[Action, State comparable, Param any] struct {
+	Item     HistoryItem[Action, State, Param]
+	Children []*HistoryNode[Action, State, Param]
+}
+
+// HistoryTree is the causal tree of the history, its roots are the outermost transitions.
+type HistoryTree[Action, State comparable, Param any] []*HistoryNode[Action, State, Param]
+
+func (fsk *FSM[Action, State, Param]) nextItemID() uint64 {
+	fsk.lastItemID++
+
+	return fsk.lastItemID
+}
+
+// HistoryTree returns the history arranged by cause, History is the same items flattened.
+//
+// Items whose parent is no longer kept by a limited history become roots.
+func (fsk *FSM[Action, State, Param]) HistoryTree() HistoryTree[Action, State, Param] {
+	return NewHistoryTree(fsk.History())
+}
+
+// NewHistoryTree arranges the given items by their ParentID, keeping their order.
+func NewHistoryTree[Action, State comparable, Param any](
+	history []HistoryItem[Action, State, Param],
+) HistoryTree[Action, State, Param] {
+	nodes := make(map[uint64]*HistoryNode[Action, State, Param], len(history))
+	for _, item := range history {
+		nodes[item.ID] = &HistoryNode[Action, State, Param]{Item: item}
+	}
+
+	tree := HistoryTree[Action, State, Param]{}
+
+	for _, item := range history {
+		node := nodes[item.ID]
+
+		if parent, ok := nodes[item.ParentID]; ok && item.ParentID != 0 && parent != node {
+			parent.Children = append(parent.Children, node)
+
+			continue
+		}
+
+		tree = append(tree, node)
+	}
+
+	return tree
+}
+
+// String renders the tree as text indented by two spaces per level.
+func (tree HistoryTree[Action, State, Param]) String() string {
+	return tree.Render("  ")
+}
+
+// Render renders the tree as text, one item per line, indented by indent per level.
+func (tree HistoryTree[Action, State, Param]) Render(indent string) string {
+	result := strings.Builder{}
+
+	var render func(nodes []*HistoryNode[Action, State, Param], level int)
+	render = func(nodes []*HistoryNode[Action, State, Param], level int) {
+		for _, node := range nodes {
+			result.WriteString(strings.Repeat(indent, level))
+			result.WriteString(renderHistoryItem(node.Item))
+			result.WriteString("\n")
+
+			render(node.Children, level+1)
+		}
+	}
+
+	render(tree, 0)
+
+	return result.String()
+}
+
+func renderHistoryItem[Action, State comparable, Param any](item HistoryItem[Action, State, Param]) string {
+	line := fmt.Sprintf("%v: %v -> %v", item.Action, item.From, item.To)
+
+	flags := []string{}
+	if item.Attempt > 0 {
+		flags = append(flags, fmt.Sprintf("attempt %d", item.Attempt))
+	}
+
+	if item.Err != nil {
+		flags = append(flags, fmt.Sprintf("error: %v", item.Err))
+	}
+
+	for _, flag := range []struct {
+		set  bool
+		name string
+	}{
+		{item.Ignored, "ignored"},
+		{item.Deferred, "deferred"},
+		{item.RolledBack, "rolled back"},
+		{item.Compensation, "compensation"},
+		{item.Duplicate, "duplicate"},
+		{item.Unauthorized, "unauthorized"},
+	} {
+		if flag.set {
+			flags = append(flags, flag.name)
+		}
+	}
+
+	if item.Duration > 0 {
+		flags = append(flags, item.Duration.String())
+	}
+
+	if len(flags) == 0 {
+		return line
+	}
+
+	return fmt.Sprintf("%s [%s]", line, strings.Join(flags, ", "))
+}
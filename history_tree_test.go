@@ -0,0 +1,142 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_history_tree(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+		done
+	)
+
+	errExpected := errors.New("expected")
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				if err := instance.Apply(ctx, "done", done); err == nil {
+					return errors.New("unexpected")
+				}
+
+				return instance.Apply(ctx, "roger", roger)
+			},
+		},
+		{
+			Name: "roger", Src: []int{open}, Dst: roger,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.Apply(ctx, "done", done)
+			},
+		},
+		{Name: "done", Src: []int{roger}, Dst: done},
+		{
+			Name: "close", Src: []int{done}, Dst: close,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return errExpected
+			},
+		},
+	}, WithFullHistory[any]())
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.ErrorIs(t, machine.Event(context.TODO(), "close"), errExpected)
+
+	tree := machine.HistoryTree()
+	require.Len(t, tree, 2)
+	require.Equal(t, "open", tree[0].Item.Action)
+	require.Len(t, tree[0].Children, 2)
+	require.Equal(t, "done", tree[0].Children[0].Item.Action)
+	require.Equal(t, "roger", tree[0].Children[1].Item.Action)
+	require.Equal(t, "done", tree[0].Children[1].Children[0].Item.Action)
+	require.Equal(t, tree[0].Children[1].Item.ID, tree[0].Children[1].Children[0].Item.ParentID)
+
+	expected := `open: 1 -> 2
+  done: 2 -> 4 [error: not found]
+  roger: 2 -> 3
+    done: 3 -> 4
+close: 4 -> 1 [error: expected]
+`
+	require.Equal(t, expected, tree.String())
+	require.Equal(t, "open: 1 -> 2\n- done: 2 -> 4 [error: not found]\n", NewHistoryTree(machine.History()[:2]).Render("- "))
+}
+
+func Test_history_tree_limited(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.Apply(ctx, "roger", roger)
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+	}, WithHistory[any](1))
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+
+	// the parent is no longer kept, so the child is a root
+	tree := machine.HistoryTree()
+	require.Len(t, tree, 1)
+	require.Equal(t, "roger", tree[0].Item.Action)
+	require.Equal(t, uint64(1), tree[0].Item.ParentID)
+}
+
+func Test_history_tree_raised_and_deferred(t *testing.T) {
+	const (
+		idle int = iota + 1
+		busy
+		shipped
+	)
+
+	machine, _ := New(idle, []Transition[string, int, any]{
+		{Name: "start", Src: []int{idle}, Dst: busy},
+		{Name: "finish", Src: []int{busy}, Dst: idle},
+		{Name: "ship", Src: []int{idle}, Dst: shipped, DeferIn: []int{busy}},
+		{
+			Name: "reset", Src: []int{shipped}, Dst: idle,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.Raise(ctx, "start")
+			},
+		},
+	}, WithFullHistory[any](), WithRunToCompletion[any]())
+
+	require.NoError(t, machine.Event(context.TODO(), "start"))
+	require.NoError(t, machine.Event(context.TODO(), "ship"))
+	require.NoError(t, machine.Event(context.TODO(), "finish"))
+	require.NoError(t, machine.Event(context.TODO(), "reset"))
+	require.Equal(t, busy, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 6)
+
+	// the replay is caused by the transition that unblocked it
+	require.Equal(t, "finish", history[2].Action)
+	require.Equal(t, "ship", history[3].Action)
+	require.True(t, history[3].Deferred)
+	require.Equal(t, history[2].ID, history[3].ParentID)
+
+	// the raised event is caused by the transition that raised it
+	require.Equal(t, "reset", history[4].Action)
+	require.Equal(t, "start", history[5].Action)
+	require.Equal(t, history[4].ID, history[5].ParentID)
+
+	expected := `start: 1 -> 2
+ship: 2 -> 3 [deferred]
+finish: 2 -> 1
+  ship: 1 -> 3 [deferred]
+reset: 3 -> 1
+  start: 1 -> 2
+`
+	require.Equal(t, expected, machine.HistoryTree().String())
+}
@@ -0,0 +1,205 @@
+package kry
+
+import (
+	"container/list"
+	"context"
+	"errors"
+)
+
+// IdempotencyRecord is the remembered result of a call made with an idempotency key.
+//
+// The failure is kept as its message, redacted by WithErrorRedactor, and its ErrorKind,
+// so the record can be persisted.
+type IdempotencyRecord[State comparable] struct {
+	Key     string
+	To      State  // state of the machine when the call finished
+	Err     string // message of the failure, "" if the call succeeded
+	ErrKind string // see ErrorKind
+}
+
+// error returns the remembered failure, it matches the sentinel of its kind with errors.Is.
+func (record IdempotencyRecord[State]) error() error {
+	if record.Err == "" && record.ErrKind == "" {
+		return nil
+	}
+
+	return &rememberedError{message: record.Err, kind: record.ErrKind}
+}
+
+type rememberedError struct {
+	message string
+	kind    string
+}
+
+func (e *rememberedError) Error() string {
+	return e.message
+}
+
+func (e *rememberedError) Is(target error) bool {
+	for _, kind := range errorKinds {
+		if target == kind {
+			return kind.Error() == e.kind
+		}
+	}
+
+	return false
+}
+
+// transientErrors may succeed if the call is made again.
+var transientErrors = []error{
+	ErrRolledBack, ErrTimeout, ErrVersionMismatch, ErrStopped,
+	context.Canceled, context.DeadlineExceeded,
+}
+
+// deterministicErrors fail again as long as the machine stays as it is.
+var deterministicErrors = []error{
+	ErrNotFound, ErrUnknown, ErrNotAllowed, ErrDone, ErrLoopFound, ErrRepeated, ErrExpired,
+}
+
+// IsDeterministicError is the default choice of the failures remembered by WithIdempotency:
+// the rejections of the machine are, the transient failures and the errors of the callbacks aren't.
+func IsDeterministicError(err error) bool {
+	if errors.Is(err, ErrEffects) || errors.Is(err, ErrRaised) {
+		return true // the transition committed, only its effects or the events it raised failed
+	}
+
+	for _, transient := range transientErrors {
+		if errors.Is(err, transient) {
+			return false
+		}
+	}
+
+	for _, deterministic := range deterministicErrors {
+		if errors.Is(err, deterministic) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// idempotencyCache is a LRU of the results of the calls made with an idempotency key.
+type idempotencyCache[State comparable] struct {
+	size  int
+	order *list.List // front is the most recently used
+	items map[string]*list.Element
+}
+
+func newIdempotencyCache[State comparable](size int) *idempotencyCache[State] {
+	if size <= 0 {
+		return nil
+	}
+
+	return &idempotencyCache[State]{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+func (cache *idempotencyCache[State]) Get(key string) (IdempotencyRecord[State], bool) {
+	element, ok := cache.items[key]
+	if !ok {
+		return IdempotencyRecord[State]{}, false
+	}
+
+	cache.order.MoveToFront(element)
+
+	record, _ := element.Value.(IdempotencyRecord[State])
+
+	return record, true
+}
+
+func (cache *idempotencyCache[State]) Put(record IdempotencyRecord[State]) {
+	if element, ok := cache.items[record.Key]; ok {
+		element.Value = record
+		cache.order.MoveToFront(element)
+
+		return
+	}
+
+	cache.items[record.Key] = cache.order.PushFront(record)
+
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+
+		record, _ := oldest.Value.(IdempotencyRecord[State])
+		delete(cache.items, record.Key)
+	}
+}
+
+// Records returns the remembered results, from the least to the most recently used.
+func (cache *idempotencyCache[State]) Records() []IdempotencyRecord[State] {
+	records := make([]IdempotencyRecord[State], 0, cache.order.Len())
+
+	for element := cache.order.Back(); element != nil; element = element.Prev() {
+		record, _ := element.Value.(IdempotencyRecord[State])
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// idempotent runs the call once per key, duplicates get the original result back
+// and are kept in the history flagged as Duplicate.
+func (fsk *FSM[Action, State, Param]) idempotent(
+	ctx context.Context,
+	key string,
+	action Action,
+	newState State,
+	call func() error,
+	param ...Param,
+) error {
+	if key == "" || fsk.idempotency == nil {
+		return call()
+	}
+
+	if record, ok := fsk.idempotency.Get(key); ok {
+		item := fsk.newItem(ctx, action, fsk.currentState, record.To, param...)
+		item.Err = record.error()
+		item.Duplicate = true
+
+		if errHistory := fsk.historyKeeper.PushItem(item, defaultSkipStackTrace); errHistory != nil {
+			return errHistory
+		}
+
+		fsk.settleHistory()
+
+		return item.Err
+	}
+
+	err := call()
+
+	switch {
+	case err == nil:
+		fsk.idempotency.Put(IdempotencyRecord[State]{Key: key, To: fsk.currentState})
+	case fsk.idempotentErrors(err):
+		message := err.Error()
+		if fsk.historyKeeper.errorRedactor != nil {
+			message = fsk.historyKeeper.errorRedactor(message) // the records are exported by Snapshot
+		}
+
+		fsk.idempotency.Put(IdempotencyRecord[State]{
+			Key:     key,
+			To:      fsk.currentState,
+			Err:     message,
+			ErrKind: ErrorKind(err),
+		})
+	}
+
+	return err
+}
+
+// restoreIdempotency replaces the remembered results by the given ones.
+func (fsk *FSM[Action, State, Param]) restoreIdempotency(records []IdempotencyRecord[State]) {
+	if fsk.idempotency == nil {
+		return
+	}
+
+	fsk.idempotency = newIdempotencyCache[State](fsk.idempotency.size)
+
+	for _, record := range records {
+		fsk.idempotency.Put(record)
+	}
+}
@@ -0,0 +1,208 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_idempotency_duplicate_returns_original_result(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	calls := 0
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				calls++
+
+				return nil
+			},
+		},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithFullHistory[any](), WithIdempotency[any](2))
+
+	options := CallOptions[string, int, any]{IdempotencyKey: "req-1"}
+
+	require.NoError(t, machine.EventWith(context.TODO(), options, "open"))
+	require.NoError(t, machine.EventWith(context.TODO(), options, "open")) // not ErrNotFound
+	require.Equal(t, 1, calls)
+	require.Equal(t, open, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 2)
+	require.False(t, history[0].Duplicate)
+	require.True(t, history[1].Duplicate)
+	require.Equal(t, open, history[1].To)
+
+	// the failures are remembered as well
+	options = CallOptions[string, int, any]{IdempotencyKey: "req-2"}
+
+	require.ErrorIs(t, machine.ApplyWith(context.TODO(), options, "open", open), ErrNotFound)
+	require.NoError(t, machine.Apply(context.TODO(), "close", close))
+	require.ErrorIs(t, machine.ApplyWith(context.TODO(), options, "open", open), ErrNotFound)
+	require.Equal(t, close, machine.Current())
+}
+
+func Test_idempotency_keys_are_bounded_and_persisted(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	transitions := []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}
+
+	machine, _ := New(close, transitions, WithIdempotency[any](2))
+
+	for _, key := range []string{"a", "b", "c"} {
+		action := "open"
+		if machine.Current() == open {
+			action = "close"
+		}
+
+		require.NoError(t, machine.EventWith(context.TODO(), CallOptions[string, int, any]{IdempotencyKey: key}, action))
+	}
+
+	snapshot := machine.Snapshot()
+	require.Equal(t, []IdempotencyRecord[int]{
+		{Key: "b", To: close},
+		{Key: "c", To: open},
+	}, snapshot.IdempotencyKeys)
+
+	restored, _ := New(close, transitions, WithIdempotency[any](2))
+	require.NoError(t, restored.Restore(snapshot))
+
+	// "c" was processed before the snapshot, so it's not applied again
+	require.NoError(t, restored.EventWith(context.TODO(), CallOptions[string, int, any]{IdempotencyKey: "c"}, "open"))
+	require.Equal(t, open, restored.Current())
+
+	// "a" was evicted, so it runs again
+	require.NoError(t, restored.EventWith(context.TODO(), CallOptions[string, int, any]{IdempotencyKey: "a"}, "close"))
+	require.Equal(t, close, restored.Current())
+}
+
+func Test_idempotency_transient_failures_run_again(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	errUnavailable := errors.New("unavailable")
+	calls := 0
+
+	transitions := []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				calls++
+				if calls == 1 {
+					return errUnavailable
+				}
+
+				return nil
+			},
+		},
+	}
+
+	machine, _ := New(close, transitions, WithIdempotency[any](2))
+	options := CallOptions[string, int, any]{IdempotencyKey: "msg-1"}
+
+	canceled, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	require.ErrorIs(t, machine.EventWith(canceled, options, "open"), context.Canceled)
+	require.ErrorIs(t, machine.EventWith(context.TODO(), options, "open"), errUnavailable)
+	require.NoError(t, machine.EventWith(context.TODO(), options, "open"))
+	require.NoError(t, machine.EventWith(context.TODO(), options, "open"))
+	require.Equal(t, 2, calls)
+
+	// the errors of the callbacks are remembered if chosen so
+	calls = 0
+	machine, _ = New(close, transitions, WithIdempotency[any](2), WithIdempotentErrors[any](func(err error) bool {
+		return errors.Is(err, errUnavailable)
+	}))
+
+	require.ErrorIs(t, machine.EventWith(context.TODO(), options, "open"), errUnavailable)
+
+	err := machine.EventWith(context.TODO(), options, "open")
+	require.ErrorContains(t, err, errUnavailable.Error())
+	require.Equal(t, 1, calls)
+
+	snapshot := machine.Snapshot()
+	require.Len(t, snapshot.IdempotencyKeys, 1)
+	require.Equal(t, "other", snapshot.IdempotencyKeys[0].ErrKind)
+	require.Contains(t, snapshot.IdempotencyKeys[0].Err, errUnavailable.Error())
+}
+
+func Test_idempotency_persisted_failure_keeps_its_kind(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	transitions := []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}
+
+	machine, _ := New(close, transitions, WithIdempotency[any](2))
+	options := CallOptions[string, int, any]{IdempotencyKey: "req-1"}
+
+	require.ErrorIs(t, machine.ApplyWith(context.TODO(), options, "close", close), ErrNotFound)
+
+	snapshot := machine.Snapshot()
+	require.Equal(t, ErrorKind(ErrNotFound), snapshot.IdempotencyKeys[0].ErrKind)
+
+	restored, _ := New(close, transitions, WithIdempotency[any](2), WithFullHistory[any]())
+	require.NoError(t, restored.Restore(snapshot))
+	require.ErrorIs(t, restored.ApplyWith(context.TODO(), options, "close", close), ErrNotFound)
+	require.True(t, restored.History()[0].Duplicate)
+}
+
+func Test_idempotency_forgotten_on_tx_rollback(t *testing.T) {
+	const (
+		idle int = iota + 1
+		reserved
+		charged
+	)
+
+	type instance = InstanceFSM[string, int, any]
+
+	errDeclined := errors.New("declined")
+	options := CallOptions[string, int, any]{IdempotencyKey: "reserve-1"}
+	reservations := 0
+
+	machine, _ := New(idle, []Transition[string, int, any]{
+		{
+			Name: "book", Src: []int{idle}, Dst: charged,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				if err := instance.ApplyWith(ctx, options, "reserve", reserved); err != nil {
+					return err
+				}
+
+				return errDeclined
+			},
+		},
+		{
+			Name: "reserve", Src: []int{charged}, Dst: reserved,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				reservations++
+
+				return nil
+			},
+		},
+	}, WithIdempotency[any](2))
+
+	require.ErrorIs(t, machine.ApplyTx(context.TODO(), "book", charged), ErrRolledBack)
+	require.Equal(t, idle, machine.Current())
+	require.Empty(t, machine.Snapshot().IdempotencyKeys)
+	require.Equal(t, 1, reservations)
+}
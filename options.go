@@ -11,6 +11,29 @@ type Options[Param any] struct {
 	stackTrace   bool
 	panicHandler PanicHandler
 	cloneHandler CloneHandler[Param]
+
+	runToCompletion bool
+	data            any
+	effectExecutor  EffectExecutor
+	retryPolicy     *RetryPolicy
+	clock           Clock
+	idempotencySize int
+	idempotentErrs  func(err error) bool
+	authorizer      any // Authorizer[Action, State], checked by New
+	stateInfos      any // map[State]StateInfo, checked by New
+	finalStates     any // []State, checked by New
+	onCompleted     []func(ctx context.Context) error
+	timings         bool
+	historySink     any // HistorySink[Action, State, Param], checked by New
+	onSinkError     func(err error)
+	redactor        func(param Param) Param
+	errorRedactor   func(message string) string
+	callRedactor    func(principal string, metadata map[string]any) (string, map[string]any)
+	dataRedactor    func(data any) any
+	hashChain       bool
+	retention       RetentionPolicy[Param]
+	forceDisabled   bool
+	forceTargets    any // []State, checked by New
 }
 
 // WithHistory enables history tracking for the FSM with a specified size.
@@ -31,6 +54,98 @@ func WithFullHistory[Param any]() func(o *Options[Param]) *Options[Param] {
 	}
 }
 
+// WithHistorySink streams every history item to the sink once the outermost call that recorded it
+// settles, whether the history is kept or not. See NewJSONLinesSink and NewCSVSink.
+//
+// The errors of the sink don't fail the transitions, see WithHistorySinkErrors and FlushHistory.
+func WithHistorySink[Param any, Action, State comparable](
+	sink HistorySink[Action, State, Param],
+) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.historySink = sink
+
+		return o
+	}
+}
+
+// WithHistorySinkErrors sets the handler of the errors of the history sink.
+//
+// The item the sink failed to take, and the following ones, are streamed again after the next call.
+func WithHistorySinkErrors[Param any](handler func(err error)) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.onSinkError = handler
+
+		return o
+	}
+}
+
+// WithRedactor redacts every param before it's kept in the history or sent to the sink,
+// the callbacks still receive the original values. The params given to the redactor
+// are already cloned, see RedactTags for a struct-tag driven redactor.
+func WithRedactor[Param any](redactor func(param Param) Param) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.redactor = redactor
+
+		return o
+	}
+}
+
+// WithCallRedactor redacts the principal and the call metadata of the items kept in the history
+// or sent to the sink. The metadata given to the redactor is a copy, so it can be changed in place.
+func WithCallRedactor[Param any](
+	redactor func(principal string, metadata map[string]any) (string, map[string]any),
+) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.callRedactor = redactor
+
+		return o
+	}
+}
+
+// WithDataRedactor redacts the extended state kept by the history items as DataBefore and DataAfter,
+// the machine keeps the original data. Keep the data as a value, see SetData, or return a copy.
+func WithDataRedactor[Param any](redactor func(data any) any) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.dataRedactor = redactor
+
+		return o
+	}
+}
+
+// WithErrorRedactor redacts the message of the errors kept in the history or sent to the sink,
+// Reason included. The kept error still wraps the original one, so errors.Is works on it.
+func WithErrorRedactor[Param any](redactor func(message string) string) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.errorRedactor = redactor
+
+		return o
+	}
+}
+
+// WithHashChain makes every history item carry the hash of its canonical encoding, which covers
+// the hash of the previous item, so VerifyHistory can tell if the history was tampered with.
+//
+// Items are hashed once the outermost call that recorded them finishes, before they are streamed
+// to the sink. The params, the data and the metadata must be encodable as JSON, otherwise
+// the item isn't kept and the call fails.
+func WithHashChain[Param any]() func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.hashChain = true
+
+		return o
+	}
+}
+
+// WithRetention bounds the kept history by age and estimated size, along with the count
+// given by WithHistory. Combined with WithFullHistory, only the retention policy applies.
+func WithRetention[Param any](policy RetentionPolicy[Param]) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.retention = policy
+
+		return o
+	}
+}
+
 // WithEnabledStackTrace enables stack trace capturing for each history item.
 func WithEnabledStackTrace[Param any]() func(o *Options[Param]) *Options[Param] {
 	return func(o *Options[Param]) *Options[Param] {
@@ -40,6 +155,152 @@ func WithEnabledStackTrace[Param any]() func(o *Options[Param]) *Options[Param]
 	}
 }
 
+// WithEnabledTimings records the start, the end and the duration of every history item,
+// taken from the clock given by WithClock.
+func WithEnabledTimings[Param any]() func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.timings = true
+
+		return o
+	}
+}
+
+// WithRunToCompletion makes Raise queue the events raised inside callbacks.
+//
+// Queued events are processed in order once the outermost transition commits,
+// and discarded if the transition that raised them fails or is ignored.
+func WithRunToCompletion[Param any]() func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.runToCompletion = true
+
+		return o
+	}
+}
+
+// WithData sets the initial extended state of the FSM.
+//
+// The data is available in callbacks through InstanceFSM.Data and InstanceFSM.SetData,
+// or typed through DataOf and UpdateData.
+func WithData[Param any](data any) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.data = data
+
+		return o
+	}
+}
+
+// WithEffectExecutor sets the executor of the effects emitted by callbacks, see TypedEffectExecutor.
+//
+// Effects are handed to the executor only after the transition, and all its nested
+// transitions, commit. Without an executor the emitted effects are discarded.
+// If the executor fails, Apply returns an error wrapping ErrEffects though the transition committed.
+func WithEffectExecutor[Param any](executor EffectExecutor) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.effectExecutor = executor
+
+		return o
+	}
+}
+
+// WithRetryPolicy sets the retry policy of every transition that doesn't define its own.
+func WithRetryPolicy[Param any](policy RetryPolicy) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.retryPolicy = &policy
+
+		return o
+	}
+}
+
+// WithClock replaces the system clock, it's meant for deterministic tests.
+func WithClock[Param any](clock Clock) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.clock = clock
+
+		return o
+	}
+}
+
+// WithIdempotency remembers the results of the last size calls made with an idempotency key.
+//
+// The successful calls are remembered, and the failures chosen by IsDeterministicError,
+// so a call that failed for a transient reason runs again when it's redelivered.
+func WithIdempotency[Param any](size int) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.idempotencySize = size
+
+		return o
+	}
+}
+
+// WithIdempotentErrors replaces IsDeterministicError to choose the failures remembered by WithIdempotency.
+func WithIdempotentErrors[Param any](remember func(err error) bool) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.idempotentErrs = remember
+
+		return o
+	}
+}
+
+// WithAuthorizer sets the function that authorizes every transition before its callbacks run.
+func WithAuthorizer[Param any, Action, State comparable](
+	authorizer Authorizer[Action, State],
+) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.authorizer = authorizer
+
+		return o
+	}
+}
+
+// WithStates registers the metadata of the states, every state must be used by a transition.
+func WithStates[Param any, State comparable](states map[State]StateInfo) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.stateInfos = states
+
+		return o
+	}
+}
+
+// WithFinalStates declares the states that complete the machine, along with StateInfo.Final.
+//
+// Once a final state is committed the machine is Done, Completed is closed
+// and any further Apply fails with ErrDone.
+func WithFinalStates[Param any, State comparable](states ...State) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.finalStates = states
+
+		return o
+	}
+}
+
+// WithOnCompleted adds a function called once the outermost transition commits a final state.
+func WithOnCompleted[Param any](onCompleted func(ctx context.Context) error) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.onCompleted = append(o.onCompleted, onCompleted)
+
+		return o
+	}
+}
+
+// WithoutForceState disables ForceState, every call fails with ErrNotAllowed and is kept in the history.
+func WithoutForceState[Param any]() func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.forceDisabled = true
+
+		return o
+	}
+}
+
+// WithForceStateTargets restricts ForceState to the given states, forcing any other state
+// fails with ErrNotAllowed and is kept in the history.
+func WithForceStateTargets[Param any, State comparable](states ...State) func(o *Options[Param]) *Options[Param] {
+	return func(o *Options[Param]) *Options[Param] {
+		o.forceTargets = states
+
+		return o
+	}
+}
+
 type PanicHandler = func(ctx context.Context, panicReason any)
 
 // WithPanicHandler sets a custom panic handler for the FSM.
@@ -55,27 +316,11 @@ type CloneHandler[Param any] = func(params ...Param) ([]Param, error)
 
 // WithCloneHandler sets a custom clone handler for the FSM.
 //
-// As example, could be used to deep copy parameters if they are complex types.
-//
-//	import "github.com/fxamacker/cbor/v2"
-//	func cloneHandler[Param any](params ...Param) ([]Param, error) {
-//		if len(params) == 0 {
-//			return params, nil
-//		}
+// The default handler only copies the params slice, so the pointers, maps and slices inside
+// the params are shared with the history. Use DeepClone to keep the history safe from later
+// mutations of complex params:
 //
-//		data, err := cbor.Marshal(params)
-//		if err != nil {
-//			return nil, fmt.Errorf("failed to marshal params: %w", err)
-//		}
-//
-//		var cloned []Param
-//
-//		if err := cbor.Unmarshal(data, &cloned); err != nil {
-//			return nil, fmt.Errorf("failed to unmarshal params: %w", err)
-//		}
-//
-//		return cloned, nil
-//	}
+//	machine, err := kry.New(initial, transitions, kry.WithCloneHandler(kry.DeepClone[Param]))
 func WithCloneHandler[Param any](handler CloneHandler[Param]) func(o *Options[Param]) *Options[Param] {
 	return func(o *Options[Param]) *Options[Param] {
 		o.cloneHandler = handler
@@ -150,6 +395,10 @@ func ExpectEnterVariadic[Action comparable, State comparable, Param any](
 	}
 }
 
+// With decorates the next transition applied by the machine, whichever it is.
+//
+// The decorators are kept on the shared instance, use ApplyWith or EventWith
+// to scope them to a single call instead.
 func (fsk *FSM[Action, State, Param]) With(opts ...func(fsk InstanceFSM[Action, State, Param]) InstanceFSM[Action, State, Param]) InstanceFSM[Action, State, Param] {
 	for _, o := range opts {
 		fsm, ok := o(fsk).(*FSM[Action, State, Param])
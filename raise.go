@@ -0,0 +1,66 @@
+package kry
+
+import (
+	"context"
+	"fmt"
+)
+
+type raisedEvent[Action comparable, Param any] struct {
+	action   Action
+	params   []Param
+	parentID uint64 // ID of the history item of the transition that raised it
+}
+
+// Raise triggers the event from inside a callback.
+//
+// If the FSM was created WithRunToCompletion and a transition is running, the event is queued
+// and processed once the outermost transition commits, using the context given to the outermost
+// Apply. Otherwise it's the same as calling Event.
+//
+// A queued event that fails doesn't revert the committed transitions, the ones queued after it
+// are dropped and Apply returns an error wrapping ErrRaised.
+func (fsk *FSM[Action, State, Param]) Raise(ctx context.Context, action Action, param ...Param) error {
+	if !fsk.runToCompletion || fsk.depth == 0 {
+		return fsk.Event(ctx, action, param...)
+	}
+
+	fsk.raised = append(fsk.raised, raisedEvent[Action, Param]{
+		action:   action,
+		params:   param,
+		parentID: fsk.parentItemID,
+	})
+
+	return nil
+}
+
+func (fsk *FSM[Action, State, Param]) drainRaised(ctx context.Context) error {
+	draining := fsk.draining
+	parentItemID := fsk.parentItemID
+	fsk.draining = true
+
+	defer func() {
+		fsk.draining = draining
+		fsk.parentItemID = parentItemID
+	}()
+
+	for len(fsk.raised) > 0 {
+		event := fsk.raised[0]
+		fsk.raised = fsk.raised[1:]
+		fsk.parentItemID = event.parentID
+		completedLength := len(fsk.completed)
+
+		if err := fsk.Event(ctx, event.action, event.params...); err != nil {
+			fsk.raised = nil
+			err = fmt.Errorf("failed to process raised event %v: %w: %w", event.action, ErrRaised, err)
+
+			// the transitions that raised it are committed, only the failed event is undone
+			if errCompensate := fsk.compensate(ctx, completedLength); errCompensate != nil {
+				err = fmt.Errorf("%w: %w", err, errCompensate)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_raise_run_to_completion_ok(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		locked
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				require.NoError(t, instance.Raise(ctx, "lock"))
+				require.Equal(t, open, instance.Current()) // lock is not processed yet
+
+				return nil
+			},
+		},
+		{
+			Name: "lock", Src: []int{open}, Dst: locked,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.Raise(ctx, "close")
+			},
+		},
+		{Name: "close", Src: []int{locked}, Dst: close},
+	}, WithFullHistory[any](), WithRunToCompletion[any]())
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.Equal(t, close, machine.Current())
+
+	expectedHistory := []HistoryItem[string, int, any]{
+		{Action: "open", From: close, To: open, Version: 1, ID: 1},
+		{Action: "lock", From: open, To: locked, Version: 2, ID: 2, ParentID: 1},
+		{Action: "close", From: locked, To: close, Version: 3, ID: 3, ParentID: 2},
+	}
+	require.Equal(t, expectedHistory, machine.History())
+}
+
+func Test_raise_discarded_on_failure(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		locked
+	)
+
+	errExpected := errors.New("expected error")
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				require.NoError(t, instance.Raise(ctx, "lock"))
+
+				return errExpected
+			},
+		},
+		{Name: "lock", Src: []int{open}, Dst: locked},
+	}, WithRunToCompletion[any]())
+
+	require.ErrorIs(t, machine.Event(context.TODO(), "open"), errExpected)
+	require.Equal(t, close, machine.Current())
+}
+
+func Test_raise_failure_keeps_the_committed_transition(t *testing.T) {
+	const (
+		a int = iota + 1
+		b
+		c
+		d
+	)
+
+	errExpected := errors.New("expected error")
+	compensated := []string{}
+
+	compensate := func(name string) handlerVariadic[string, int, any] {
+		return func(ctx context.Context, instance InstanceFSM[string, int, any], param ...any) error {
+			compensated = append(compensated, name)
+
+			return nil
+		}
+	}
+
+	machine, _ := New(a, []Transition[string, int, any]{
+		{
+			Name: "go", Src: []int{a}, Dst: b,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.Raise(ctx, "next")
+			},
+			Compensate: compensate("go"),
+		},
+		{
+			Name: "next", Src: []int{b}, Dst: c,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				if err := instance.Apply(ctx, "book", d); err != nil {
+					return err
+				}
+
+				return errExpected
+			},
+		},
+		{Name: "book", Src: []int{c}, Dst: d, Compensate: compensate("book")},
+	}, WithFullHistory[any](), WithRunToCompletion[any]())
+
+	err := machine.Event(context.TODO(), "go")
+	require.ErrorIs(t, err, ErrRaised)
+	require.ErrorIs(t, err, errExpected)
+
+	// go is committed and kept, only the steps of the failed raised event are undone
+	require.Equal(t, b, machine.Current())
+	require.Equal(t, uint64(1), machine.Version())
+	require.Equal(t, []string{"book"}, compensated)
+	require.True(t, IsDeterministicError(err))
+}
+
+func Test_raise_without_run_to_completion_is_nested(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		locked
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				require.NoError(t, instance.Raise(ctx, "lock"))
+				require.Equal(t, locked, instance.Current())
+
+				return nil
+			},
+		},
+		{Name: "lock", Src: []int{open}, Dst: locked},
+	})
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.Equal(t, locked, machine.Current())
+}
@@ -0,0 +1,235 @@
+package kry
+
+import "reflect"
+
+const (
+	redactTag      = "kry"
+	redactTagValue = "redact"
+
+	// Redacted replaces the string fields tagged with `kry:"redact"`.
+	Redacted = "[REDACTED]"
+)
+
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactTags is a redactor for WithRedactor. It returns a copy of the param where the exported
+// fields tagged with `kry:"redact"` are replaced by Redacted if they are strings, or zeroed otherwise.
+// Tagged fields that are already zero are left as they are.
+//
+// Structs are followed through pointers, interfaces, slices, arrays and maps, the param itself
+// is never modified. Cycles are kept as such in the copy, and a param holding one is always copied.
+func RedactTags[Param any](param Param) Param {
+	value := reflect.ValueOf(&param).Elem()
+
+	r := &redactor{visited: map[visit]reflect.Value{}}
+
+	redacted, changed := r.redact(value)
+	if !changed {
+		return param
+	}
+
+	result, _ := redacted.Interface().(Param)
+
+	return result
+}
+
+// redactor remembers the pointers it copied, so the cycles end where they started.
+type redactor struct {
+	visited map[visit]reflect.Value
+}
+
+// redact returns a redacted copy of value, or value itself if nothing was redacted.
+func (r *redactor) redact(value reflect.Value) (reflect.Value, bool) {
+	switch value.Kind() {
+	case reflect.Interface:
+		if value.IsNil() {
+			return value, false
+		}
+
+		redacted, changed := r.redact(value.Elem())
+		if !changed {
+			return value, false
+		}
+
+		result := reflect.New(value.Type()).Elem()
+		result.Set(redacted)
+
+		return result, true
+
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value, false
+		}
+
+		key := visit{pointer: value.Pointer(), typ: value.Type()}
+		if result, ok := r.visited[key]; ok {
+			return result, true
+		}
+
+		// taken as redacted until known, a cycle back to it then points to the copy
+		result := reflect.New(value.Type().Elem())
+		r.visited[key] = result
+
+		redacted, changed := r.redact(value.Elem())
+		if !changed {
+			delete(r.visited, key)
+
+			return value, false
+		}
+
+		result.Elem().Set(redacted)
+
+		return result, true
+
+	case reflect.Struct:
+		return r.redactStruct(value)
+
+	case reflect.Array:
+		return r.redactElements(value, copyList(value))
+
+	case reflect.Slice:
+		if value.IsNil() {
+			return value, false
+		}
+
+		key := visit{pointer: value.Pointer(), typ: value.Type(), length: value.Len(), capacity: value.Cap()}
+		if result, ok := r.visited[key]; ok {
+			return result, true
+		}
+
+		result := copyList(value)
+		r.visited[key] = result
+
+		redacted, changed := r.redactElements(value, result)
+		if !changed {
+			delete(r.visited, key)
+		}
+
+		return redacted, changed
+
+	case reflect.Map:
+		if value.IsNil() {
+			return value, false
+		}
+
+		key := visit{pointer: value.Pointer(), typ: value.Type()}
+		if result, ok := r.visited[key]; ok {
+			return result, true
+		}
+
+		result := reflect.MakeMapWithSize(value.Type(), value.Len())
+		r.visited[key] = result
+
+		changed := false
+
+		iterator := value.MapRange()
+		for iterator.Next() {
+			redacted, changedValue := r.redact(iterator.Value())
+			result.SetMapIndex(iterator.Key(), redacted)
+
+			changed = changed || changedValue
+		}
+
+		if !changed {
+			delete(r.visited, key)
+
+			return value, false
+		}
+
+		return result, true
+
+	default:
+		return value, false
+	}
+}
+
+// redactElements redacts the elements of value into result, a copy of it.
+func (r *redactor) redactElements(value, result reflect.Value) (reflect.Value, bool) {
+	changed := false
+
+	for index := range value.Len() {
+		redacted, changedElement := r.redact(value.Index(index))
+		if !changedElement {
+			continue
+		}
+
+		result.Index(index).Set(redacted)
+
+		changed = true
+	}
+
+	if !changed {
+		return value, false
+	}
+
+	return result, true
+}
+
+func copyList(value reflect.Value) reflect.Value {
+	if value.Kind() == reflect.Array {
+		result := reflect.New(value.Type()).Elem()
+		result.Set(value)
+
+		return result
+	}
+
+	result := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+	reflect.Copy(result, value)
+
+	return result
+}
+
+func (r *redactor) redactStruct(value reflect.Value) (reflect.Value, bool) {
+	var result reflect.Value
+
+	structType := value.Type()
+
+	for index := range structType.NumField() {
+		field := structType.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+
+		var redacted reflect.Value
+
+		if field.Tag.Get(redactTag) == redactTagValue {
+			if value.Field(index).IsZero() {
+				continue // nothing to hide
+			}
+
+			redacted = reflect.New(field.Type).Elem()
+			if field.Type.Kind() == reflect.String {
+				redacted.SetString(Redacted)
+			}
+		} else {
+			var changed bool
+			if redacted, changed = r.redact(value.Field(index)); !changed {
+				continue
+			}
+		}
+
+		if !result.IsValid() {
+			result = reflect.New(structType).Elem()
+			result.Set(value)
+		}
+
+		result.Field(index).Set(redacted)
+	}
+
+	if !result.IsValid() {
+		return value, false
+	}
+
+	return result, true
+}
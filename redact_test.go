@@ -0,0 +1,204 @@
+package kry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type payment struct {
+	Amount int
+	Card   string `kry:"redact"`
+	CVV    int    `kry:"redact"`
+	Payer  *payer
+}
+
+type payer struct {
+	Name  string
+	Email string `kry:"redact"`
+}
+
+func Test_redact_tags(t *testing.T) {
+	original := payment{Amount: 10, Card: "4111", CVV: 123, Payer: &payer{Name: "Ann", Email: "ann@example.com"}}
+
+	redacted := RedactTags(original)
+	require.Equal(t, payment{Amount: 10, Card: Redacted, Payer: &payer{Name: "Ann", Email: Redacted}}, redacted)
+	require.Equal(t, "4111", original.Card)
+	require.Equal(t, "ann@example.com", original.Payer.Email)
+
+	var param any = []any{original, map[string]payer{"p": {Email: "x"}}, 42}
+	require.Equal(t, []any{
+		payment{Amount: 10, Card: Redacted, Payer: &payer{Name: "Ann", Email: Redacted}},
+		map[string]payer{"p": {Email: Redacted}},
+		42,
+	}, RedactTags(param))
+
+	untouched := &payer{Name: "Bob"}
+	require.Same(t, untouched, RedactTags(untouched))
+}
+
+func Test_redactor_in_history_and_sink(t *testing.T) {
+	const (
+		pending int = iota + 1
+		paid
+	)
+
+	var received payment
+
+	buffer := &bytes.Buffer{}
+
+	machine, _ := New(pending, []Transition[string, int, any]{
+		{
+			Name: "pay", Src: []int{pending}, Dst: paid,
+			Enter: func(ctx context.Context, instance InstanceFSM[string, int, any], param any) error {
+				received, _ = param.(payment)
+
+				return fmt.Errorf("card %s declined", received.Card)
+			},
+		},
+	},
+		WithFullHistory[any](),
+		WithEnabledStackTrace[any](),
+		WithRedactor(RedactTags[any]),
+		WithErrorRedactor[any](func(message string) string {
+			return strings.ReplaceAll(message, "4111", "****")
+		}),
+		WithHistorySink[any](NewJSONLinesSink[string, int, any](buffer)),
+	)
+
+	err := machine.Event(context.TODO(), "pay", payment{Amount: 10, Card: "4111"})
+	require.ErrorContains(t, err, "4111") // the caller still gets the original error
+	require.Equal(t, "4111", received.Card)
+
+	history := machine.History()
+	require.Len(t, history, 1)
+	require.Equal(t, []any{payment{Amount: 10, Card: Redacted}}, history[0].Params)
+	require.Equal(t, "card **** declined", history[0].Err.Error())
+	require.Equal(t, "card **** declined", history[0].Reason)
+
+	require.NotContains(t, buffer.String(), "4111")
+	require.Contains(t, buffer.String(), Redacted)
+}
+
+type account struct {
+	Token string `kry:"redact"`
+	Next  *account
+	Links map[string]any
+}
+
+func Test_redact_tags_cycles(t *testing.T) {
+	original := &account{Token: "secret"}
+	original.Next = original
+
+	redacted := RedactTags(original)
+	require.Equal(t, Redacted, redacted.Token)
+	require.Same(t, redacted, redacted.Next)
+	require.Equal(t, "secret", original.Token)
+
+	links := map[string]any{}
+	links["self"] = links
+	links["account"] = account{Token: "secret"}
+
+	withMap := RedactTags(account{Links: links})
+	require.Equal(t, account{Token: Redacted}, withMap.Links["account"])
+	require.Equal(t, account{Token: "secret"}, links["account"])
+
+	self, _ := withMap.Links["self"].(map[string]any)
+	require.Equal(t, account{Token: Redacted}, self["account"])
+
+	clean := &account{}
+	clean.Next = clean
+
+	copied := RedactTags(clean)
+	require.Empty(t, copied.Token)
+	require.Same(t, copied, copied.Next)
+}
+
+func Test_call_redactor(t *testing.T) {
+	const (
+		pending int = iota + 1
+		paid
+	)
+
+	buffer := &bytes.Buffer{}
+
+	machine, _ := New(pending, []Transition[string, int, any]{
+		{Name: "pay", Src: []int{pending}, Dst: paid},
+	},
+		WithFullHistory[any](),
+		WithCallRedactor[any](func(principal string, metadata map[string]any) (string, map[string]any) {
+			metadata["card"] = Redacted
+
+			return Redacted, metadata
+		}),
+		WithHistorySink[any](NewJSONLinesSink[string, int, any](buffer)),
+	)
+
+	metadata := map[string]any{"card": "4111", "channel": "web"}
+
+	require.NoError(t, machine.EventWith(context.TODO(), CallOptions[string, int, any]{
+		Principal: "ann@example.com",
+		Metadata:  metadata,
+	}, "pay"))
+	require.Equal(t, "4111", metadata["card"])
+
+	history := machine.History()
+	require.Len(t, history, 1)
+	require.Equal(t, Redacted, history[0].Principal)
+	require.Equal(t, map[string]any{"card": Redacted, "channel": "web"}, history[0].CallMetadata)
+
+	require.NotContains(t, buffer.String(), "4111")
+	require.NotContains(t, buffer.String(), "ann@example.com")
+}
+
+func Test_redactors_in_snapshot_and_data(t *testing.T) {
+	const (
+		pending int = iota + 1
+		paid
+	)
+
+	type wallet struct {
+		Owner string
+		Card  string `kry:"redact"`
+	}
+
+	machine, _ := New(pending, []Transition[string, int, any]{
+		{
+			Name: "pay", Src: []int{pending}, Dst: paid,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				instance.SetData(wallet{Owner: "ann", Card: "4111"})
+
+				return fmt.Errorf("card 4111: %w", ErrNotAllowed)
+			},
+		},
+	},
+		WithFullHistory[any](),
+		WithIdempotency[any](10),
+		WithData[any](wallet{Owner: "ann", Card: "4000"}),
+		WithDataRedactor[any](RedactTags[any]),
+		WithErrorRedactor[any](func(message string) string {
+			return strings.ReplaceAll(message, "4111", "****")
+		}),
+	)
+
+	options := CallOptions[string, int, any]{IdempotencyKey: "k-1"}
+	require.ErrorIs(t, machine.EventWith(context.TODO(), options, "pay"), ErrNotAllowed)
+
+	records := machine.Snapshot().IdempotencyKeys
+	require.Len(t, records, 1)
+	require.Contains(t, records[0].Err, "card ****: not allowed")
+	require.NotContains(t, records[0].Err, "4111")
+
+	err := machine.EventWith(context.TODO(), options, "pay")
+	require.ErrorIs(t, err, ErrNotAllowed)
+	require.NotContains(t, err.Error(), "4111")
+
+	history := machine.History()
+	require.Equal(t, wallet{Owner: "ann", Card: Redacted}, history[0].DataBefore)
+	require.Equal(t, wallet{Owner: "ann", Card: Redacted}, history[0].DataAfter)
+	require.Equal(t, wallet{Owner: "ann", Card: "4000"}, machine.Data()) // rolled back, not redacted
+}
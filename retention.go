@@ -0,0 +1,173 @@
+package kry
+
+import (
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// RetentionPolicy bounds the history by the age and the estimated size of its items,
+// the oldest items are dropped first. Zero values mean no limit.
+type RetentionPolicy[Param any] struct {
+	MaxAge   time.Duration // items kept longer than this are dropped, measured by the clock of the machine
+	MaxBytes int           // approximate, the newest item is kept even if it's bigger
+
+	// KeepErrors drops the oldest items without error first when the history is over its count
+	// or MaxBytes, so a chatty action doesn't push the recent errors out. The errors still expire
+	// by MaxAge. It can't be combined with WithHashChain, the chain can't have gaps.
+	KeepErrors bool
+
+	// SizeOf estimates the bytes of a param,
+	// by default only the size of the Param type itself is counted.
+	SizeOf func(param Param) int
+
+	// DataSizeOf estimates the bytes of the extended state kept by DataBefore and DataAfter,
+	// by default only the size of its type is counted, or the length of a string.
+	DataSizeOf func(data any) int
+}
+
+func (policy RetentionPolicy[Param]) sizeOf(param Param) int {
+	if policy.SizeOf != nil {
+		return policy.SizeOf(param)
+	}
+
+	return int(unsafe.Sizeof(param))
+}
+
+func (policy RetentionPolicy[Param]) dataSizeOf(data any) int {
+	if data == nil {
+		return 0
+	}
+
+	if policy.DataSizeOf != nil {
+		return policy.DataSizeOf(data)
+	}
+
+	return sizeOfValue(data)
+}
+
+func sizeOfValue(value any) int {
+	if text, ok := value.(string); ok {
+		return len(text)
+	}
+
+	if value == nil {
+		return 0
+	}
+
+	return int(reflect.TypeOf(value).Size())
+}
+
+func sizeOfMap(values map[string]any) int {
+	size := 0
+	for key, value := range values {
+		size += len(key) + sizeOfValue(value)
+	}
+
+	return size
+}
+
+// estimateSize returns the approximate bytes taken by the item.
+func estimateSize[Action, State comparable, Param any](
+	item *HistoryItem[Action, State, Param],
+	policy RetentionPolicy[Param],
+) int {
+	size := int(unsafe.Sizeof(*item)) +
+		len(item.StackTrace) + len(item.Reason) + len(item.Principal) + len(item.Description) + len(item.ForceReason) +
+		len(item.Hash) + len(item.PrevHash) +
+		sizeOfMap(item.Meta) + sizeOfMap(item.CallMetadata) +
+		policy.dataSizeOf(item.DataBefore) + policy.dataSizeOf(item.DataAfter)
+
+	for _, tag := range item.Tags {
+		size += len(tag)
+	}
+
+	for _, param := range item.Params {
+		size += policy.sizeOf(param)
+	}
+
+	return size
+}
+
+// retain stamps the item with what the retention policy needs to know about it.
+func (hk *historyKeeper[Action, State, Param]) retain(item *historyItem[Action, State, Param]) {
+	if hk.retention.MaxBytes > 0 {
+		item.size = estimateSize(item.HistoryItem, hk.retention)
+	}
+
+	if hk.retention.MaxAge > 0 {
+		item.pushedAt = hk.clock.Now()
+	}
+}
+
+// evict drops the oldest items beyond the count, the age or the bytes allowed, it's called under lock.
+func (hk *historyKeeper[Action, State, Param]) evict() {
+	var now time.Time
+	if hk.retention.MaxAge > 0 {
+		now = hk.clock.Now()
+	}
+
+	for hk.head != nil {
+		if hk.retention.MaxAge > 0 && now.Sub(hk.head.pushedAt) > hk.retention.MaxAge {
+			hk.remove(hk.head)
+
+			continue
+		}
+
+		overCount := hk.maxLength > 0 && hk.length > hk.maxLength
+		overBytes := hk.retention.MaxBytes > 0 && hk.bytes > hk.retention.MaxBytes && hk.length > 1
+
+		if !overCount && !overBytes {
+			return
+		}
+
+		hk.remove(hk.victim())
+	}
+}
+
+// victim returns the item to drop when the history is too long or too big.
+func (hk *historyKeeper[Action, State, Param]) victim() *historyItem[Action, State, Param] {
+	if !hk.retention.KeepErrors {
+		return hk.head
+	}
+
+	// the newest item is never dropped for the sake of the errors
+	for current := hk.head; current != hk.tail; current = current.Next {
+		if current.Err == nil {
+			return current
+		}
+	}
+
+	return hk.head
+}
+
+// remove unlinks the item, its Next is left as it is for the walks already holding it.
+func (hk *historyKeeper[Action, State, Param]) remove(item *historyItem[Action, State, Param]) {
+	if item == hk.sealed {
+		hk.sealed = item.Prev // lastHash still links the chain
+	}
+
+	hk.bytes -= item.size
+	hk.length--
+
+	if item == hk.head {
+		hk.head = item.Next
+	} else {
+		item.Prev.Next = item.Next
+	}
+
+	if item == hk.tail {
+		hk.tail = item.Prev
+	} else {
+		item.Next.Prev = item.Prev
+	}
+
+	if hk.head == nil || hk.length == 0 {
+		hk.head = nil
+		hk.tail = nil
+
+		return
+	}
+
+	hk.head.Prev = nil
+}
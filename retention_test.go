@@ -0,0 +1,211 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_retention_max_age(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithFullHistory[any](), WithClock[any](clock), WithRetention(RetentionPolicy[any]{MaxAge: time.Minute}))
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	clock.now = clock.now.Add(30 * time.Second)
+	require.NoError(t, machine.Event(context.TODO(), "close"))
+	clock.now = clock.now.Add(31 * time.Second)
+
+	history := machine.History()
+	require.Len(t, history, 1)
+	require.Equal(t, "close", history[0].Action)
+
+	clock.now = clock.now.Add(time.Minute)
+	require.Empty(t, machine.History())
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.Len(t, machine.History(), 1)
+}
+
+func Test_retention_max_bytes(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, err := New(close, []Transition[string, int, string]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithFullHistory[string](), WithRetention(RetentionPolicy[string]{
+		MaxBytes: 3000,
+		SizeOf: func(param string) int {
+			return len(param)
+		},
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "open", "small"))
+	require.NoError(t, machine.Event(context.TODO(), "close", string(make([]byte, 2000))))
+	require.NoError(t, machine.Event(context.TODO(), "open", string(make([]byte, 2000))))
+
+	history := machine.History()
+	require.Len(t, history, 1)
+	require.Equal(t, "open", history[0].Action)
+
+	// the newest item is kept even if it's bigger than allowed
+	require.NoError(t, machine.Event(context.TODO(), "close", string(make([]byte, 5000))))
+
+	history = machine.History()
+	require.Len(t, history, 1)
+	require.Equal(t, "close", history[0].Action)
+}
+
+func Test_retention_keeps_recent_errors(t *testing.T) {
+	const (
+		idle int = iota + 1
+		broken
+	)
+
+	errExpected := errors.New("expected")
+
+	machine, err := New(idle, []Transition[string, int, any]{
+		{Name: "ping", Src: []int{idle}, Dst: idle},
+		{
+			Name: "break", Src: []int{idle}, Dst: broken,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return errExpected
+			},
+		},
+	}, WithHistory[any](10), WithRetention(RetentionPolicy[any]{KeepErrors: true}))
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "ping"))
+	require.ErrorIs(t, machine.Event(context.TODO(), "break"), errExpected)
+
+	for range 1000 {
+		require.NoError(t, machine.Event(context.TODO(), "ping"))
+	}
+
+	history := machine.History()
+	require.Len(t, history, 10)
+	require.Equal(t, "break", history[0].Action)
+	require.ErrorIs(t, history[0].Err, errExpected)
+
+	for _, item := range history[1:] {
+		require.Equal(t, "ping", item.Action)
+	}
+
+	// once only errors are left, the oldest ones are dropped
+	for range 12 {
+		require.ErrorIs(t, machine.Event(context.TODO(), "break"), errExpected)
+	}
+
+	history = machine.History()
+	require.Len(t, history, 10)
+
+	for _, item := range history {
+		require.ErrorIs(t, item.Err, errExpected)
+	}
+
+	// without KeepErrors the chatty action pushes the error out
+	chatty, err := New(idle, []Transition[string, int, any]{
+		{Name: "ping", Src: []int{idle}, Dst: idle},
+		{
+			Name: "break", Src: []int{idle}, Dst: broken,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return errExpected
+			},
+		},
+	}, WithHistory[any](10))
+	require.NoError(t, err)
+
+	require.ErrorIs(t, chatty.Event(context.TODO(), "break"), errExpected)
+
+	for range 10 {
+		require.NoError(t, chatty.Event(context.TODO(), "ping"))
+	}
+
+	require.Empty(t, slices.Collect(chatty.HistoryQuery().Errors().All()))
+}
+
+func Test_retention_max_bytes_counts_metadata_and_data(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Tags: []string{string(make([]byte, 1000))},
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				instance.SetData(string(make([]byte, 1000)))
+
+				return nil
+			},
+		},
+		{Name: "close", Src: []int{open}, Dst: close, Meta: map[string]any{"note": string(make([]byte, 1000))}},
+	}, WithFullHistory[any](), WithRetention(RetentionPolicy[any]{MaxBytes: 2000}))
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.NoError(t, machine.Event(context.TODO(), "close"))
+
+	history := machine.History()
+	require.Len(t, history, 1)
+	require.Equal(t, "close", history[0].Action)
+
+	_, err = New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+	}, WithHashChain[any](), WithRetention(RetentionPolicy[any]{KeepErrors: true}))
+	require.ErrorIs(t, err, ErrNotAllowed)
+}
+
+func Test_retention_with_count_and_hash_chain(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithHistory[any](3), WithHashChain[any](), WithClock[any](clock),
+		WithRetention(RetentionPolicy[any]{MaxAge: time.Minute}))
+	require.NoError(t, err)
+
+	for range 3 {
+		require.NoError(t, machine.Event(context.TODO(), "open"))
+		require.NoError(t, machine.Event(context.TODO(), "close"))
+		clock.now = clock.now.Add(20 * time.Second)
+	}
+
+	history := machine.History()
+	require.Len(t, history, 3)
+	require.Equal(t, history[0].Hash, history[1].PrevHash)
+	require.Equal(t, history[1].Hash, history[2].PrevHash)
+
+	clock.now = clock.now.Add(time.Minute)
+	require.Empty(t, machine.History())
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+
+	history = machine.History()
+	require.Len(t, history, 1)
+	require.NotEmpty(t, history[0].PrevHash) // still chained to the dropped items
+}
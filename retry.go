@@ -0,0 +1,74 @@
+package kry
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultBackoffMultiplier = 2
+)
+
+// RetryPolicy tells how to retry the Enter* callbacks that fail.
+//
+// Every attempt is kept in the history with its attempt counter. Between attempts the machine
+// is set back to the state the callback started with, so nested transitions start over too.
+type RetryPolicy struct {
+	MaxAttempts    int           // attempts in total, the first one included; below 2 retries are disabled
+	InitialBackoff time.Duration // wait before the second attempt
+	MaxBackoff     time.Duration // optional upper limit of the wait
+	Multiplier     float64       // growth of the wait between attempts, 2 if not set
+	Jitter         float64       // fraction of the wait, from 0 to 1, that is randomized
+
+	// Retryable classifies the errors returned by the callbacks, every error is retried if not set.
+	Retryable func(err error) bool
+}
+
+func (policy *RetryPolicy) enabled() bool {
+	return policy != nil && policy.MaxAttempts > 1
+}
+
+func (policy *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if !policy.enabled() || attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	if errors.Is(err, ErrLoopFound) {
+		return false // it will fail again
+	}
+
+	if policy.Retryable != nil {
+		return policy.Retryable(err)
+	}
+
+	return true
+}
+
+// backoff returns how long to wait after the given failed attempt.
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultBackoffMultiplier
+	}
+
+	wait := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if policy.MaxBackoff > 0 && wait > float64(policy.MaxBackoff) {
+		wait = float64(policy.MaxBackoff)
+	}
+
+	if policy.Jitter > 0 {
+		wait -= wait * min(policy.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(wait)
+}
+
+func (fsk *FSM[Action, State, Param]) retryPolicyFor(callbacks callbacks[Action, State, Param]) *RetryPolicy {
+	if callbacks.Retry != nil {
+		return callbacks.Retry
+	}
+
+	return fsk.retryPolicy
+}
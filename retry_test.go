@@ -0,0 +1,232 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, duration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.sleeps = append(c.sleeps, duration)
+	c.now = c.now.Add(duration)
+
+	return nil
+}
+
+func Test_retry_enter_until_success(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	errFlaky := errors.New("flaky")
+	clock := &fakeClock{}
+	calls := 0
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				calls++
+				if calls < 3 {
+					return errFlaky
+				}
+
+				return nil
+			},
+		},
+	},
+		WithFullHistory[any](),
+		WithClock[any](clock),
+		WithRetryPolicy[any](RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     150 * time.Millisecond,
+		}),
+	)
+
+	require.NoError(t, machine.Apply(context.TODO(), "open", open))
+	require.Equal(t, open, machine.Current())
+	require.Equal(t, 3, calls)
+	require.Equal(t, []time.Duration{100 * time.Millisecond, 150 * time.Millisecond}, clock.sleeps)
+
+	expectedHistory := []HistoryItem[string, int, any]{
+		{Action: "open", From: close, To: open, Err: errFlaky, Attempt: 1, ID: 1},
+		{Action: "open", From: close, To: open, Err: errFlaky, Attempt: 2, ID: 2},
+		{Action: "open", From: close, To: open, Attempt: 3, Version: 1, ID: 3},
+	}
+	require.Equal(t, expectedHistory, machine.History())
+}
+
+func Test_retry_attempts_dont_share_metadata(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	calls := 0
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Tags:  []string{"door"},
+			Meta:  map[string]any{"floor": 1},
+			Retry: &RetryPolicy{MaxAttempts: 2},
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				calls++
+				if calls < 2 {
+					return errors.New("flaky")
+				}
+
+				return nil
+			},
+		},
+	}, WithFullHistory[any](), WithClock[any](&fakeClock{}))
+
+	require.NoError(t, machine.ApplyWith(context.TODO(), CallOptions[string, int, any]{
+		Metadata: map[string]any{"request": "r-1"},
+	}, "open", open))
+
+	history := machine.History()
+	require.Len(t, history, 2)
+
+	history[0].Tags[0] = "changed"
+	history[0].Meta["floor"] = 2
+	history[0].CallMetadata["request"] = "changed"
+
+	history = machine.History()
+	require.Equal(t, []string{"door"}, history[1].Tags)
+	require.Equal(t, map[string]any{"floor": 1}, history[1].Meta)
+	require.Equal(t, map[string]any{"request": "r-1"}, history[1].CallMetadata)
+}
+
+func Test_retry_per_transition_not_retryable(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	errPermanent := errors.New("permanent")
+	clock := &fakeClock{}
+	calls := 0
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Retry: &RetryPolicy{
+				MaxAttempts: 3,
+				Retryable: func(err error) bool {
+					return !errors.Is(err, errPermanent)
+				},
+			},
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				calls++
+
+				return errPermanent
+			},
+		},
+	}, WithFullHistory[any](), WithClock[any](clock))
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "open", open), errPermanent)
+	require.Equal(t, close, machine.Current())
+	require.Equal(t, 1, calls)
+	require.Empty(t, clock.sleeps)
+	require.Equal(t, 1, machine.History()[0].Attempt)
+}
+
+func Test_retry_backoff_exponential_with_jitter(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Second,
+		Multiplier:     3,
+	}
+
+	require.Equal(t, time.Second, policy.backoff(1))
+	require.Equal(t, 3*time.Second, policy.backoff(2))
+	require.Equal(t, 9*time.Second, policy.backoff(3))
+
+	policy.Jitter = 0.5
+	for range 20 {
+		wait := policy.backoff(2)
+		require.LessOrEqual(t, wait, 3*time.Second)
+		require.GreaterOrEqual(t, wait, 1500*time.Millisecond)
+	}
+}
+
+func Test_retry_compensates_nested_steps_of_failed_attempts(t *testing.T) {
+	const (
+		idle int = iota + 1
+		booking
+		reserved
+	)
+
+	type instance = InstanceFSM[string, int, any]
+
+	errFlaky := errors.New("flaky")
+	clock := &fakeClock{}
+	calls := 0
+	reservations := 0
+
+	machine, _ := New(idle, []Transition[string, int, any]{
+		{
+			Name: "book", Src: []int{idle}, Dst: booking,
+			Retry: &RetryPolicy{MaxAttempts: 3},
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				if err := instance.Apply(ctx, "reserve", reserved); err != nil {
+					return err
+				}
+
+				calls++
+				if calls < 2 {
+					return errFlaky
+				}
+
+				return nil
+			},
+		},
+		{
+			Name: "reserve", Src: []int{booking}, Dst: reserved,
+			EnterNoParams: func(ctx context.Context, instance instance) error {
+				reservations++
+
+				return nil
+			},
+			Compensate: func(ctx context.Context, instance instance, param ...any) error {
+				reservations--
+
+				return nil
+			},
+		},
+	}, WithFullHistory[any](), WithClock[any](clock))
+
+	require.NoError(t, machine.Apply(context.TODO(), "book", booking))
+	require.Equal(t, reserved, machine.Current())
+	require.Equal(t, 2, calls)
+	require.Equal(t, 1, reservations)
+
+	history := machine.History()
+	require.Len(t, history, 5)
+	require.Equal(t, errFlaky, history[0].Err)
+	require.Equal(t, "reserve", history[1].Action)
+	require.Equal(t, "reserve", history[2].Action)
+	require.True(t, history[2].Compensation)
+	require.Equal(t, history[0].ID, history[2].ParentID)
+	require.Equal(t, 2, history[3].Attempt)
+	require.Equal(t, "reserve", history[4].Action)
+}
@@ -0,0 +1,62 @@
+package kry
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Snapshot is the restorable part of the FSM, transitions and options are not included.
+type Snapshot[State comparable] struct {
+	Current  State
+	Previous State
+	Data     any
+	Version  uint64
+
+	PendingEffects []any // committed effects that were not executed successfully yet
+
+	// IdempotencyKeys are the remembered results of the calls made with an idempotency key,
+	// leave them out to not persist them.
+	IdempotencyKeys []IdempotencyRecord[State]
+}
+
+// Snapshot returns the current state of the machine, so it can be persisted and restored later.
+func (fsk *FSM[Action, State, Param]) Snapshot() Snapshot[State] {
+	snapshot := Snapshot[State]{
+		Current:  fsk.currentState,
+		Previous: fsk.previousState,
+		Data:     fsk.data,
+		Version:  fsk.version,
+
+		PendingEffects: slices.Clone(fsk.pendingEffects),
+	}
+
+	if fsk.idempotency != nil {
+		snapshot.IdempotencyKeys = fsk.idempotency.Records()
+	}
+
+	return snapshot
+}
+
+// Restore sets the machine to the given snapshot. It's not allowed while a transition is running.
+//
+// The pending effects of the snapshot are executed on the next FlushEffects or committed transition.
+func (fsk *FSM[Action, State, Param]) Restore(snapshot Snapshot[State]) error {
+	if fsk.depth > 0 {
+		return fmt.Errorf("restore during a transition: %w", ErrNotAllowed)
+	}
+
+	if _, ok := fsk.states[snapshot.Current]; !ok {
+		return fmt.Errorf("state %w: %v", ErrUnknown, snapshot.Current)
+	}
+
+	fsk.currentState = snapshot.Current
+	fsk.previousState = snapshot.Previous
+	fsk.data = snapshot.Data
+	fsk.version = snapshot.Version
+	fsk.syncCompleted()
+	fsk.pendingEffects = slices.Clone(snapshot.PendingEffects)
+
+	fsk.restoreIdempotency(snapshot.IdempotencyKeys)
+
+	return nil
+}
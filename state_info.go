@@ -0,0 +1,119 @@
+package kry
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// StateInfo describes a state, so the State type can stay a plain enum.
+type StateInfo struct {
+	Name        string // display name, the state value is used if empty
+	Description string
+	Tags        []string
+	Final       bool          // no transition is expected out of the state
+	SLA         time.Duration // how long the machine is expected to stay in the state, 0 means no limit
+	Color       string        // any color understood by graphviz
+}
+
+// HasTag reports whether the state is tagged with tag.
+func (info StateInfo) HasTag(tag string) bool {
+	return slices.Contains(info.Tags, tag)
+}
+
+// StateInfo returns the metadata registered for the state by WithStates.
+func (fsk *FSM[Action, State, Param]) StateInfo(state State) (StateInfo, bool) {
+	info, ok := fsk.stateInfos[state]
+
+	return info, ok
+}
+
+// StateName returns the display name of the state, or the state value if it has none.
+func (fsk *FSM[Action, State, Param]) StateName(state State) string {
+	if info, ok := fsk.stateInfos[state]; ok && info.Name != "" {
+		return info.Name
+	}
+
+	return fmt.Sprint(state)
+}
+
+func constructStateInfos[State comparable](
+	stateInfos any,
+	states map[State]struct{},
+) (map[State]StateInfo, error) {
+	if stateInfos == nil {
+		return nil, nil
+	}
+
+	infos, ok := stateInfos.(map[State]StateInfo)
+	if !ok {
+		return nil, fmt.Errorf("states %T don't match the machine: %w", stateInfos, ErrNotAllowed)
+	}
+
+	for state := range infos {
+		if _, ok := states[state]; !ok {
+			return nil, fmt.Errorf("state %w: %v", ErrUnknown, state)
+		}
+	}
+
+	return maps.Clone(infos), nil
+}
+
+// VisualizeStates renders the DOT nodes of the given states, final states are drawn as double circles.
+func VisualizeStates[State comparable](states map[State]StateInfo) string {
+	result := strings.Builder{}
+
+	keys := slices.SortedFunc(maps.Keys(states), func(a, b State) int {
+		return cmp.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	})
+
+	for _, state := range keys {
+		info := states[state]
+		attributes := []string{}
+
+		if info.Name != "" {
+			attributes = append(attributes, fmt.Sprintf(`label = "%s"`, escapeDOT(info.Name)))
+		}
+
+		if tooltip := visualizeStateTooltip(info); tooltip != "" {
+			attributes = append(attributes, fmt.Sprintf(`tooltip = "%s"`, tooltip))
+		}
+
+		if info.Color != "" {
+			attributes = append(attributes, fmt.Sprintf(`color = "%s"`, escapeDOT(info.Color)))
+		}
+
+		if info.Final {
+			attributes = append(attributes, "shape = doublecircle")
+		}
+
+		node := ""
+		if len(attributes) > 0 {
+			node = fmt.Sprintf(` [ %s ]`, strings.Join(attributes, ", "))
+		}
+
+		result.WriteString(fmt.Sprintf(`%s"%v"%s;%s`, "\t", state, node, "\n"))
+	}
+
+	return result.String()
+}
+
+func visualizeStateTooltip(info StateInfo) string {
+	lines := []string{}
+	if info.Description != "" {
+		lines = append(lines, info.Description)
+	}
+
+	if len(info.Tags) > 0 {
+		lines = append(lines, "tags: "+strings.Join(info.Tags, ", "))
+	}
+
+	if info.SLA > 0 {
+		lines = append(lines, "sla: "+info.SLA.String())
+	}
+
+	return escapeDOT(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,62 @@
+package kry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_state_infos_registered(t *testing.T) {
+	const (
+		draft int = iota
+		published
+		archived
+	)
+
+	machine, err := New(draft, []Transition[string, int, any]{
+		{Name: "publish", Src: []int{draft}, Dst: published},
+		{Name: "archive", Src: []int{published}, Dst: archived},
+	}, WithStates[any](map[int]StateInfo{
+		draft:     {Name: "Draft", Tags: []string{"editable"}},
+		published: {Name: "Published", SLA: 24 * time.Hour},
+		archived:  {Final: true, Color: "grey"},
+	}))
+	require.NoError(t, err)
+
+	info, ok := machine.StateInfo(draft)
+	require.True(t, ok)
+	require.True(t, info.HasTag("editable"))
+
+	require.Equal(t, "Published", machine.StateName(published))
+	require.Equal(t, "2", machine.StateName(archived))
+	require.Contains(t, machine.String(), `"1" [ label = "Published", tooltip = "sla: 24h0m0s" ];`)
+
+	_, err = New(draft, []Transition[string, int, any]{
+		{Name: "publish", Src: []int{draft}, Dst: published},
+	}, WithStates[any](map[int]StateInfo{archived: {}}))
+	require.ErrorIs(t, err, ErrUnknown)
+
+	_, err = New(draft, []Transition[string, int, any]{
+		{Name: "publish", Src: []int{draft}, Dst: published},
+	}, WithStates[any](map[string]StateInfo{"draft": {}}))
+	require.ErrorIs(t, err, ErrNotAllowed)
+}
+
+func Test_state_infos_visualization(t *testing.T) {
+	expected := strings.Join([]string{
+		`	"0" [ label = "Draft", tooltip = "Being written\ntags: editable" ];`,
+		`	"1";`,
+		`	"2" [ color = "grey", shape = doublecircle ];`,
+		``,
+	}, "\n")
+
+	actual := VisualizeStates(map[int]StateInfo{
+		0: {Name: "Draft", Description: "Being written", Tags: []string{"editable"}},
+		1: {},
+		2: {Final: true, Color: "grey"},
+	})
+
+	require.Equal(t, expected, actual)
+}
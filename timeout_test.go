@@ -0,0 +1,76 @@
+package kry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apply_refused_on_done_context(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				t.Fatal("enter should not be called")
+
+				return nil
+			},
+		},
+	}, WithFullHistory[any]())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, machine.Apply(ctx, "open", open), context.Canceled)
+	require.Equal(t, close, machine.Current())
+	require.ErrorIs(t, machine.History()[0].Err, context.Canceled)
+}
+
+func Test_apply_timeout_rolls_back(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			Timeout: 10 * time.Millisecond,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				<-ctx.Done()
+
+				return ctx.Err()
+			},
+		},
+		{
+			Name: "roger", Src: []int{close}, Dst: roger,
+			Timeout: time.Millisecond,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				time.Sleep(5 * time.Millisecond) // ignores ctx, but the deadline is still checked
+
+				return nil
+			},
+		},
+	}, WithFullHistory[any]())
+
+	err := machine.Apply(context.Background(), "open", open)
+	require.ErrorIs(t, err, ErrTimeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, close, machine.Current())
+
+	require.ErrorIs(t, machine.Apply(context.Background(), "roger", roger), ErrTimeout)
+	require.Equal(t, close, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 2)
+	require.ErrorIs(t, history[0].Err, ErrTimeout)
+	require.ErrorIs(t, history[1].Err, ErrTimeout)
+}
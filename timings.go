@@ -0,0 +1,62 @@
+package kry
+
+import "time"
+
+func (fsk *FSM[Action, State, Param]) startTimer(item *HistoryItem[Action, State, Param]) {
+	if !fsk.timings {
+		return
+	}
+
+	item.Start = fsk.clock.Now()
+	item.End = item.Start
+	item.Duration = 0
+}
+
+func (fsk *FSM[Action, State, Param]) stopTimer(item *HistoryItem[Action, State, Param]) {
+	if !fsk.timings {
+		return
+	}
+
+	item.End = fsk.clock.Now()
+	item.Duration = item.End.Sub(item.Start)
+}
+
+// DwellTimes returns how long the machine stayed in every state, computed from the history
+// kept with WithEnabledTimings. The current state counts until now.
+func (fsk *FSM[Action, State, Param]) DwellTimes() map[State]time.Duration {
+	return DwellTimes(fsk.History(), fsk.clock.Now())
+}
+
+// DwellTimes returns how long the machine stayed in every state according to the history,
+// the last state counts until the given time.
+//
+// Only the committed transitions with timings are taken into account, and a state
+// is entered when its transition starts, as the callbacks already run in it.
+func DwellTimes[Action, State comparable, Param any](
+	history []HistoryItem[Action, State, Param],
+	until time.Time,
+) map[State]time.Duration {
+	dwell := map[State]time.Duration{}
+
+	var entered *HistoryItem[Action, State, Param]
+
+	for index := range history {
+		item := &history[index]
+		if item.Start.IsZero() || item.Err != nil || item.Ignored || item.RolledBack ||
+			item.Duplicate || item.Compensation {
+			continue
+		}
+
+		if entered != nil {
+			dwell[entered.To] += item.Start.Sub(entered.Start)
+		}
+
+		entered = item
+	}
+
+	if entered != nil && until.After(entered.Start) {
+		dwell[entered.To] += until.Sub(entered.Start)
+	}
+
+	return dwell
+}
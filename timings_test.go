@@ -0,0 +1,90 @@
+package kry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_timings_in_history(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				clock.now = clock.now.Add(time.Second)
+
+				return instance.Apply(ctx, "roger", roger)
+			},
+		},
+		{
+			Name: "roger", Src: []int{open}, Dst: roger,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				clock.now = clock.now.Add(2 * time.Second)
+
+				return nil
+			},
+		},
+	}, WithFullHistory[any](), WithEnabledTimings[any](), WithClock[any](clock))
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	require.ErrorIs(t, machine.Event(context.TODO(), "open"), ErrNotFound)
+
+	require.Equal(t, []HistoryItem[string, int, any]{
+		{
+			Action: "open", From: close, To: open, Version: 2,
+			Start: start, End: start.Add(3 * time.Second), Duration: 3 * time.Second,
+			ID: 1,
+		},
+		{
+			Action: "roger", From: open, To: roger, Version: 1, Depth: 1,
+			Start: start.Add(time.Second), End: start.Add(3 * time.Second), Duration: 2 * time.Second,
+			ID:       2,
+			ParentID: 1,
+		},
+		{
+			Action: "open", From: roger, To: open, Err: ErrNotFound, Version: 2,
+			Start: start.Add(3 * time.Second), End: start.Add(3 * time.Second),
+			ID: 3,
+		},
+	}, machine.History())
+}
+
+func Test_dwell_times(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}, WithFullHistory[any](), WithEnabledTimings[any](), WithClock[any](clock))
+
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	clock.now = clock.now.Add(time.Minute)
+	require.NoError(t, machine.Event(context.TODO(), "close"))
+	clock.now = clock.now.Add(time.Second)
+	require.ErrorIs(t, machine.Event(context.TODO(), "close"), ErrNotFound) // not counted
+	clock.now = clock.now.Add(time.Second)
+	require.NoError(t, machine.Event(context.TODO(), "open"))
+	clock.now = clock.now.Add(time.Hour)
+
+	require.Equal(t, map[int]time.Duration{
+		open:  time.Minute + time.Hour,
+		close: 2 * time.Second,
+	}, machine.DwellTimes())
+}
@@ -0,0 +1,87 @@
+package kry
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+// TransitionInfo describes a transition without its callbacks.
+type TransitionInfo[Action, State comparable] struct {
+	Name Action
+	Src  []State // empty if the transition matches its source states by SrcFn
+	Dst  State
+
+	Roles       []string
+	Description string
+	Tags        []string
+	Meta        map[string]any
+}
+
+// HasTag reports whether the transition is tagged with tag.
+func (info TransitionInfo[Action, State]) HasTag(tag string) bool {
+	return slices.Contains(info.Tags, tag)
+}
+
+// HasTag reports whether the transition of the item is tagged with tag.
+func (item HistoryItem[Action, State, Param]) HasTag(tag string) bool {
+	return slices.Contains(item.Tags, tag)
+}
+
+func newTransitionInfo[Action, State comparable, Param any](
+	transition Transition[Action, State, Param],
+) *TransitionInfo[Action, State] {
+	info := TransitionInfo[Action, State]{
+		Name:        transition.Name,
+		Src:         transition.Src,
+		Dst:         transition.Dst,
+		Roles:       transition.Roles,
+		Description: transition.Description,
+		Tags:        transition.Tags,
+		Meta:        transition.Meta,
+	}.clone()
+
+	return &info
+}
+
+// clone returns a copy of the info that doesn't share its slices and maps.
+func (info TransitionInfo[Action, State]) clone() TransitionInfo[Action, State] {
+	info.Src = slices.Clone(info.Src)
+	info.Roles = slices.Clone(info.Roles)
+	info.Tags = slices.Clone(info.Tags)
+	info.Meta = maps.Clone(info.Meta)
+
+	return info
+}
+
+func newTransitionInfos[Action, State comparable, Param any](
+	transitions []Transition[Action, State, Param],
+) []TransitionInfo[Action, State] {
+	infos := make([]TransitionInfo[Action, State], 0, len(transitions))
+	for _, transition := range transitions {
+		infos = append(infos, *newTransitionInfo(transition))
+	}
+
+	return infos
+}
+
+// Transitions returns the description of the transitions the machine was created with, in order.
+func (fsk *FSM[Action, State, Param]) Transitions() []TransitionInfo[Action, State] {
+	infos := make([]TransitionInfo[Action, State], 0, len(fsk.transitions))
+	for _, info := range fsk.transitions {
+		infos = append(infos, info.clone())
+	}
+
+	return infos
+}
+
+// TransitionFromContext returns the transition being applied, it's available to the authorizer
+// and to the Enter* callbacks.
+func TransitionFromContext[Action, State comparable](ctx context.Context) (TransitionInfo[Action, State], bool) {
+	info, ok := ctx.Value(transitionKey).(*TransitionInfo[Action, State])
+	if !ok || info == nil {
+		return TransitionInfo[Action, State]{}, false
+	}
+
+	return info.clone(), true
+}
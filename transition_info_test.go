@@ -0,0 +1,138 @@
+package kry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_transition_metadata_in_history(t *testing.T) {
+	const (
+		draft int = iota + 1
+		published
+	)
+
+	var seen TransitionInfo[string, int]
+
+	machine, _ := New(draft, []Transition[string, int, any]{
+		{
+			Name: "publish", Src: []int{draft}, Dst: published,
+			Description: "Make the post public",
+			Tags:        []string{"public", "audit"},
+			Meta:        map[string]any{"owner": "editorial"},
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				seen, _ = TransitionFromContext[string, int](ctx)
+
+				return nil
+			},
+		},
+		{Name: "unpublish", Src: []int{published}, Dst: draft},
+	}, WithFullHistory[any]())
+
+	require.NoError(t, machine.Event(context.TODO(), "publish"))
+	require.NoError(t, machine.Event(context.TODO(), "unpublish"))
+
+	require.Equal(t, "Make the post public", seen.Description)
+	require.True(t, seen.HasTag("audit"))
+
+	history := machine.History()
+	require.Len(t, history, 2)
+	require.Equal(t, "Make the post public", history[0].Description)
+	require.Equal(t, map[string]any{"owner": "editorial"}, history[0].Meta)
+	require.True(t, history[0].HasTag("public"))
+	require.False(t, history[1].HasTag("public"))
+
+	transitions := machine.Transitions()
+	require.Len(t, transitions, 2)
+	require.Equal(t, "publish", transitions[0].Name)
+	require.Equal(t, []int{draft}, transitions[0].Src)
+	require.Equal(t, published, transitions[0].Dst)
+	require.True(t, transitions[0].HasTag("audit"))
+
+	_, ok := TransitionFromContext[string, int](context.TODO())
+	require.False(t, ok)
+}
+
+func Test_transition_metadata_not_shared(t *testing.T) {
+	const (
+		draft int = iota + 1
+		published
+	)
+
+	transitions := []Transition[string, int, any]{
+		{
+			Name: "publish", Src: []int{draft}, Dst: published,
+			Tags:  []string{"public"},
+			Meta:  map[string]any{"owner": "editorial"},
+			Roles: []string{"publisher"},
+		},
+		{
+			Name: "unpublish", Src: []int{published}, Dst: draft,
+			Tags: []string{"private"},
+		},
+	}
+
+	machine, _ := New(draft, transitions, WithFullHistory[any](), WithAuthorizer[any](RolesAuthorizer[string, int](
+		func(ctx context.Context, principal string) []string {
+			if principal == "alice" {
+				return []string{"publisher"}
+			}
+
+			return nil
+		},
+	)))
+
+	alice := ContextWithPrincipal(context.TODO(), "alice")
+
+	require.ErrorIs(t, machine.Event(context.TODO(), "publish"), ErrNotAllowed)
+	require.NoError(t, machine.Event(alice, "publish"))
+	require.NoError(t, machine.Event(alice, "unpublish"))
+
+	transitions[0].Tags[0] = "changed"
+	transitions[0].Meta["owner"] = "changed"
+
+	// the rejected item and the committed one
+	history := machine.History()
+	require.True(t, history[0].Unauthorized)
+
+	for _, index := range []int{0, 1} {
+		history[index].Tags[0] = "changed"
+		history[index].Meta["owner"] = "changed"
+	}
+
+	infos := machine.Transitions()
+	infos[0].Tags[0] = "changed"
+
+	require.NoError(t, machine.Event(alice, "publish"))
+
+	history = machine.History()
+	require.Len(t, history, 4)
+	require.Equal(t, []string{"public"}, history[3].Tags)
+	require.Equal(t, map[string]any{"owner": "editorial"}, history[3].Meta)
+	require.Equal(t, []string{"public"}, machine.Transitions()[0].Tags)
+	require.Equal(t, map[string]any{"owner": "editorial"}, machine.Transitions()[0].Meta)
+}
+
+func Test_transition_metadata_visualization(t *testing.T) {
+	const (
+		draft int = iota
+		published
+	)
+
+	expected := `	"0" -> "1" [ label = "Make the \"post\" public", tooltip = "tags: public, audit\nowner=editorial\npriority=1" ];
+	"1" -> "0";
+`
+
+	actual := VisualizeStateLinks([]Transition[string, int, any]{
+		{
+			Name: "publish", Src: []int{draft}, Dst: published,
+			Description: `Make the "post" public`,
+			Tags:        []string{"public", "audit"},
+			Meta:        map[string]any{"priority": 1, "owner": "editorial"},
+		},
+		{Name: "unpublish", Src: []int{published}, Dst: draft},
+	})
+
+	require.Equal(t, expected, actual)
+}
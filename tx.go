@@ -0,0 +1,135 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+type transaction struct {
+	err error // the first failure found in the chain, even if a callback swallowed it
+}
+
+// Step is a single transition of a sequence given to ApplySequence.
+type Step[Action, State comparable, Param any] struct {
+	Action Action
+	Dst    State
+	Params []Param
+}
+
+// ApplyTx is like Apply, but the whole chain of nested transitions is atomic.
+//
+// If any nested Apply fails, even if the callback that called it swallows the error,
+// the machine returns to the state it had before ApplyTx and the error wraps ErrRolledBack.
+// The history items of the chain are kept, flagged as RolledBack.
+func (fsk *FSM[Action, State, Param]) ApplyTx(
+	ctx context.Context, action Action, newState State, param ...Param,
+) error {
+	if err := fsk.atomically(ctx, func() error {
+		return fsk.Apply(ctx, action, newState, param...)
+	}); err != nil {
+		return fmt.Errorf("transaction (%v): %w", action, err)
+	}
+
+	return nil
+}
+
+// ApplySequence applies the steps one after another as a single transaction.
+//
+// If a step fails, the compensations of the completed steps run in reverse order,
+// and the machine returns to the state it had before the sequence.
+func (fsk *FSM[Action, State, Param]) ApplySequence(
+	ctx context.Context, steps ...Step[Action, State, Param],
+) error {
+	if err := fsk.atomically(ctx, func() error {
+		for index, step := range steps {
+			if err := fsk.Apply(ctx, step.Action, step.Dst, step.Params...); err != nil {
+				return fmt.Errorf("step %d (%v): %w", index, step.Action, err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("sequence: %w", err)
+	}
+
+	return nil
+}
+
+func (fsk *FSM[Action, State, Param]) atomically(ctx context.Context, fn func() error) error {
+	if fsk.tx != nil {
+		return fn()
+	}
+
+	defer fsk.settleHistory()
+
+	currentAction := fsk.currentAction
+	snapshot := fsk.Snapshot()
+	deferred := slices.Clone(fsk.deferred)
+	completedLength := len(fsk.completed)
+	effectsLength := len(fsk.effects)
+
+	fsk.historyKeeper.locker.Lock()
+	tail := fsk.historyKeeper.tail
+	pendingLength := len(fsk.historyKeeper.pending)
+	fsk.historyKeeper.locker.Unlock()
+
+	rollback := func() error {
+		fsk.currentAction = currentAction
+		fsk.currentState = snapshot.Current
+		fsk.previousState = snapshot.Previous
+		fsk.data = snapshot.Data
+		fsk.version = snapshot.Version
+		fsk.deferred = deferred
+		fsk.restoreIdempotency(snapshot.IdempotencyKeys)
+		fsk.raised = nil
+		fsk.effects = fsk.effects[:effectsLength]
+
+		fsk.historyKeeper.MarkRolledBack(tail, pendingLength)
+
+		return fsk.compensate(ctx, completedLength)
+	}
+
+	tx := &transaction{}
+	fsk.tx = tx
+
+	defer func() {
+		fsk.tx = nil
+
+		if errPanic := recover(); errPanic != nil {
+			_ = rollback() // the panic is what the caller gets
+
+			panic(errPanic)
+		}
+	}()
+
+	err := fn()
+
+	fsk.tx = nil
+
+	if err == nil {
+		err = tx.err
+	}
+
+	if err == nil {
+		if fsk.depth == 0 {
+			fsk.completed = fsk.completed[:completedLength]
+
+			errEffects := fsk.FlushEffects(ctx)
+			if err := fsk.complete(ctx); err != nil {
+				return errors.Join(err, errEffects)
+			}
+
+			return errEffects
+		}
+
+		return nil
+	}
+
+	if errCompensate := rollback(); errCompensate != nil {
+		err = fmt.Errorf("%w: %w", err, errCompensate)
+	}
+
+	return fmt.Errorf("%w: %w", ErrRolledBack, err)
+}
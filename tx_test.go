@@ -0,0 +1,132 @@
+package kry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_apply_tx_rollback_whole_chain(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+		locked
+	)
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				if err := instance.Apply(ctx, "roger", roger); err != nil {
+					return err
+				}
+
+				_ = instance.Apply(ctx, "lock", locked) // the error is swallowed on purpose
+
+				return nil
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+		{Name: "lock", Src: []int{open}, Dst: locked},
+	}, WithFullHistory[any]())
+	require.NoError(t, err)
+
+	require.ErrorIs(t, machine.ApplyTx(context.TODO(), "open", open), ErrRolledBack)
+	require.Equal(t, close, machine.Current())
+
+	history := machine.History()
+	require.Len(t, history, 3)
+
+	for _, item := range history {
+		require.True(t, item.RolledBack)
+	}
+
+	require.Equal(t, "lock", history[2].Action)
+	require.ErrorIs(t, history[2].Err, ErrNotFound)
+}
+
+func Test_apply_without_tx_commits_partially(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+		locked
+	)
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				if err := instance.Apply(ctx, "roger", roger); err != nil {
+					return err
+				}
+
+				_ = instance.Apply(ctx, "lock", locked) // the error is swallowed on purpose
+
+				return nil
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+		{Name: "lock", Src: []int{open}, Dst: locked},
+	}, WithFullHistory[any]())
+	require.NoError(t, err)
+
+	require.NoError(t, machine.Apply(context.TODO(), "open", open))
+	require.Equal(t, roger, machine.Current())
+
+	for _, item := range machine.History() {
+		require.False(t, item.RolledBack)
+	}
+}
+
+func Test_apply_tx_panic_restores_the_machine(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+		locked
+	)
+
+	shouldPanic := true
+
+	machine, err := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				if err := instance.Apply(ctx, "roger", roger); err != nil {
+					return err
+				}
+
+				if shouldPanic {
+					panic("boom")
+				}
+
+				_ = instance.Apply(ctx, "lock", locked) // the error is swallowed on purpose
+
+				return nil
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+		{Name: "lock", Src: []int{open}, Dst: locked},
+	}, WithFullHistory[any]())
+	require.NoError(t, err)
+
+	require.Panics(t, func() {
+		_ = machine.ApplyTx(context.TODO(), "open", open)
+	})
+	require.Nil(t, machine.tx)
+	require.Equal(t, close, machine.Current())
+	require.Equal(t, uint64(0), machine.Version())
+
+	for _, item := range machine.History() {
+		require.True(t, item.RolledBack)
+	}
+
+	// the next transaction is still atomic
+	shouldPanic = false
+
+	require.ErrorIs(t, machine.ApplyTx(context.TODO(), "open", open), ErrRolledBack)
+	require.Equal(t, close, machine.Current())
+}
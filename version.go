@@ -0,0 +1,38 @@
+package kry
+
+import (
+	"context"
+	"fmt"
+)
+
+// Version returns how many transitions were committed so far, ForceState and Restore included.
+//
+// The version of a rolled back transition is given back, so two machines with the same version
+// loaded from the same snapshot are in the same state.
+func (fsk *FSM[Action, State, Param]) Version() uint64 {
+	return fsk.version
+}
+
+// ApplyIfVersion applies the action only if the machine is at the expected version,
+// otherwise it fails with ErrVersionMismatch. It's the compare-and-swap for Apply.
+func (fsk *FSM[Action, State, Param]) ApplyIfVersion(
+	ctx context.Context,
+	expectedVersion uint64,
+	action Action,
+	newState State,
+	param ...Param,
+) error {
+	if fsk.version != expectedVersion {
+		err := error(ErrVersionMismatch)
+		if errHistory := fsk.keepFailure(ctx, action, fsk.currentState, newState, err, param...); errHistory != nil {
+			err = fmt.Errorf("%w: failed to push history item: %w", err, errHistory)
+		}
+
+		fsk.settleHistory()
+
+		return fmt.Errorf("action (%v) expected version %d, got %d: %w",
+			action, expectedVersion, fsk.version, err)
+	}
+
+	return fsk.Apply(ctx, action, newState, param...)
+}
@@ -0,0 +1,77 @@
+package kry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_version_increments_on_commit_only(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+		roger
+	)
+
+	errExpected := errors.New("expected")
+
+	machine, _ := New(close, []Transition[string, int, any]{
+		{
+			Name: "open", Src: []int{close}, Dst: open,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return instance.Apply(ctx, "roger", roger)
+			},
+		},
+		{Name: "roger", Src: []int{open}, Dst: roger},
+		{
+			Name: "close", Src: []int{roger}, Dst: close,
+			EnterNoParams: func(ctx context.Context, instance InstanceFSM[string, int, any]) error {
+				return errExpected
+			},
+		},
+	})
+
+	require.Equal(t, uint64(0), machine.Version())
+
+	require.NoError(t, machine.Apply(context.TODO(), "open", open))
+	require.Equal(t, uint64(2), machine.Version()) // the nested transition is committed too
+
+	require.ErrorIs(t, machine.Apply(context.TODO(), "close", close), errExpected)
+	require.Equal(t, uint64(2), machine.Version())
+
+	require.NoError(t, machine.ForceState(context.TODO(), close, "manual fix"))
+	require.Equal(t, uint64(3), machine.Version())
+}
+
+func Test_apply_if_version(t *testing.T) {
+	const (
+		close int = iota + 1
+		open
+	)
+
+	transitions := []Transition[string, int, any]{
+		{Name: "open", Src: []int{close}, Dst: open},
+		{Name: "close", Src: []int{open}, Dst: close},
+	}
+
+	replica1, _ := New(close, transitions, WithFullHistory[any]())
+	replica2, _ := New(close, transitions)
+
+	require.NoError(t, replica1.ApplyIfVersion(context.TODO(), 0, "open", open))
+	require.NoError(t, replica2.Restore(replica1.Snapshot()))
+
+	require.NoError(t, replica2.ApplyIfVersion(context.TODO(), 1, "close", close))
+	require.Equal(t, uint64(2), replica2.Version())
+
+	// replica1 loaded version 0 before replica2 committed, so it has to reload
+	err := replica1.ApplyIfVersion(context.TODO(), 0, "close", close)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+	require.Equal(t, open, replica1.Current())
+
+	require.Equal(t, []HistoryItem[string, int, any]{
+		{Action: "open", From: close, To: open, Version: 1, ID: 1},
+		{Action: "close", From: open, To: close, Err: ErrVersionMismatch, Version: 1, ID: 2},
+	}, replica1.History())
+}
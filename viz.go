@@ -2,9 +2,11 @@ package kry
 
 import (
 	"fmt"
+	"maps"
 	"reflect"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 )
 
@@ -28,6 +30,24 @@ func obtainFuncName(fn any) string {
 	return funcEnterName
 }
 
+func escapeDOT(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(text)
+}
+
+// visualizeTooltip renders the tags and the meta of a transition, meta keys are sorted.
+func visualizeTooltip(tags []string, meta map[string]any) string {
+	lines := []string{}
+	if len(tags) > 0 {
+		lines = append(lines, "tags: "+strings.Join(tags, ", "))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(meta)) {
+		lines = append(lines, fmt.Sprintf("%s=%v", key, meta[key]))
+	}
+
+	return escapeDOT(strings.Join(lines, "\n"))
+}
+
 func VisualizeStateLinks[Action, State comparable, Param any](transitions []Transition[Action, State, Param]) string {
 	result := strings.Builder{}
 
@@ -37,8 +57,15 @@ func VisualizeStateLinks[Action, State comparable, Param any](transitions []Tran
 		funcEnterName := obtainFuncName(transition.Enter)
 		funcEnterVariadicName := obtainFuncName(transition.EnterVariadic)
 
+		tooltip := visualizeTooltip(transition.Tags, transition.Meta)
+
 		for _, src := range transition.Src {
-			label := ""
+			attributes := []string{}
+			labels := []string{}
+			if transition.Description != "" {
+				labels = append(labels, escapeDOT(transition.Description))
+			}
+
 			if funcEnterName != "" || funcEnterNoParamsName != "" || funcEnterVariadicName != "" {
 				fns := []string{}
 				if funcEnterNoParamsName != "" {
@@ -50,7 +77,20 @@ func VisualizeStateLinks[Action, State comparable, Param any](transitions []Tran
 				if funcEnterVariadicName != "" {
 					fns = append(fns, fmt.Sprintf("enterV=%s", funcEnterVariadicName))
 				}
-				label = fmt.Sprintf(` [ label = "%s" ]`, strings.Join(fns, ", "))
+				labels = append(labels, strings.Join(fns, ", "))
+			}
+
+			if len(labels) > 0 {
+				attributes = append(attributes, fmt.Sprintf(`label = "%s"`, strings.Join(labels, `\n`)))
+			}
+
+			if tooltip != "" {
+				attributes = append(attributes, fmt.Sprintf(`tooltip = "%s"`, tooltip))
+			}
+
+			label := ""
+			if len(attributes) > 0 {
+				label = fmt.Sprintf(` [ %s ]`, strings.Join(attributes, ", "))
 			}
 
 			stateTransition := fmt.Sprintf(`%s"%v" -> "%v"%s;%s`, "\t", src, transition.Dst, label, "\n")